@@ -0,0 +1,42 @@
+package dbreaker
+
+import "context"
+
+// auditKey is the type of the context keys read by DisableWithContext
+type auditKey string
+
+// context keys DisableWithContext reads who is making the change from,
+// to be set with context.WithValue to string values
+var (
+	ActorKey         = auditKey("actor")
+	CorrelationIDKey = auditKey("correlation-id")
+)
+
+// Audit identifies who changed the breaker by DisableWithContext, and why
+type Audit struct {
+	Actor         string // from the ActorKey value of the context
+	CorrelationID string // from the CorrelationIDKey value of the context
+}
+
+// auditOf returns the audit information in ctx
+func auditOf(ctx context.Context) Audit {
+	actor, _ := ctx.Value(ActorKey).(string)
+	id, _ := ctx.Value(CorrelationIDKey).(string)
+	return Audit{Actor: actor, CorrelationID: id}
+}
+
+// DisableWithContext is Disable, recording who made the change from the
+// values of ActorKey and CorrelationIDKey in ctx, for audit trails. They are
+// set as the Audit of the event reporting the change and logged with it, and
+// are kept as the LastAudit of Stats whether or not the state changed.
+func (w *Breaker) DisableWithContext(ctx context.Context, off bool) {
+	audit := auditOf(ctx)
+	w.mu.Lock()
+	w.cancelReenable()
+	changed := w.disable(off)
+	w.mu.Unlock()
+	w.stats.lastAudit.Store(&audit)
+	if changed {
+		w.notifyBy(audit)
+	}
+}
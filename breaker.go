@@ -9,89 +9,748 @@ import (
 	"context"
 	"database/sql"
 	"database/sql/driver"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // ErrDown is returned when circuit breaker is enabled
 var ErrDown = fmt.Errorf("database is down")
 
-// ErrContext is returned when context operations are not supported
+// DownError is returned while the breaker is down, reporting the data source
+// name that was refused and the state of the breaker at the time. The state
+// is Closed when something other than the circuit refused it, such as
+// DisableName, DisableReads or DisableWrites, and is then left out of the
+// message.
+//
+// It wraps ErrDown, so errors.Is(err, ErrDown) reports whether the breaker
+// refused an operation.
+type DownError struct {
+	DSN   string
+	State CircuitState
+}
+
+func (e *DownError) Error() string {
+	if e.State == Closed {
+		return ErrDown.Error()
+	}
+	return fmt.Sprintf("%v (breaker %v)", ErrDown, e.State)
+}
+
+// Unwrap returns ErrDown
+func (e *DownError) Unwrap() error {
+	return ErrDown
+}
+
+// ErrReadOnly is returned when a write is attempted in read-only mode
+var ErrReadOnly = fmt.Errorf("database is read-only")
+
+// ErrContext is returned when context operations are not supported,
+// such as transaction options for a driver without driver.ConnBeginTx
 var ErrContext = fmt.Errorf("context operations are not supported")
 
+// ContextError is returned for transaction options the inner driver does
+// not support, reporting the data source name and the options requested.
+//
+// It wraps ErrContext, so errors.Is(err, ErrContext) reports whether the
+// options were refused.
+type ContextError struct {
+	DSN  string
+	Opts driver.TxOptions
+}
+
+func (e *ContextError) Error() string {
+	var opts []string
+	if e.Opts.Isolation != driver.IsolationLevel(sql.LevelDefault) {
+		opts = append(opts, "isolation level "+sql.IsolationLevel(e.Opts.Isolation).String())
+	}
+	if e.Opts.ReadOnly {
+		opts = append(opts, "read-only")
+	}
+	return fmt.Sprintf("%v: transaction options %s", ErrContext, strings.Join(opts, ", "))
+}
+
+// Unwrap returns ErrContext
+func (e *ContextError) Unwrap() error {
+	return ErrContext
+}
+
+// ErrOverloaded is returned when the operations allowed by WithMaxConcurrent
+// are already in flight
+var ErrOverloaded = fmt.Errorf("database is overloaded")
+
+// ErrBlocked is returned for statements in a category blocked by SetBlockedCategories
+var ErrBlocked = fmt.Errorf("statement category is blocked")
+
+// ErrClosed is returned for new connections once the driver has been closed
+var ErrClosed = fmt.Errorf("database driver is closed")
+
+// ErrNoConn is returned, wrapped with the name of the driver, when a
+// driver opens neither a connection nor an error
+var ErrNoConn = fmt.Errorf("driver returned no connection and no error")
+
 // Downer is an sql driver that can be disabled
 type Downer interface {
 	driver.Driver
 	Disable(bool)
+	IsDown() bool
 }
 
 // NewDriver registers and returns a driver wrapper that can control access to the inner driver
 func NewDriver(name, native string) (Downer, error) {
+	return NewDriverWithOptions(name, native)
+}
+
+// NewDriverWithOptions is NewDriver with configuration applied by opts.
+//
+// A name can only be used by one Breaker at a time, but once that Breaker
+// is closed the name may be used again, see Close.
+func NewDriverWithOptions(name, native string, opts ...Option) (Downer, error) {
+	regMu.Lock()
+	defer regMu.Unlock()
+	old, reuse := registry[name]
+	if reuse && !old.isClosed() {
+		return nil, fmt.Errorf("driver %q is already registered", name)
+	}
+	registered := false
 	for _, d := range sql.Drivers() {
-		if d == name {
+		if d == name && !reuse {
 			return nil, fmt.Errorf("driver %q is already registered", name)
 		}
+		registered = registered || d == native
 	}
-	drv := &Breaker{
-		native: native,
-		dbs:    make(map[string]*sql.DB),
+	if !registered {
+		return nil, fmt.Errorf("native driver %q is not registered (forgotten import?)", native)
+	}
+	drv := newBreaker(native, append([]Option{WithName(name)}, opts...)...)
+	registry[name] = drv
+	if !reuse {
+		sql.Register(name, proxy(name))
 	}
-	sql.Register(name, drv)
 	return drv, nil
 }
 
+// CloneWithOptions registers a new driver called name for the native driver,
+// configured as w is with extra options applied on top, e.g. for a second
+// breaker to split reads and writes between two databases.
+//
+// The clone is made with the options w was made with, along with what has
+// been set on w since by SetAutoTrip, OnStateChange and SetDownError, but
+// shares none of its state: it starts enabled and closed, with counters of
+// its own, whatever the state of w.
+func (w *Breaker) CloneWithOptions(name, native string, extra ...Option) (Downer, error) {
+	cfg := w.circuit.config()
+	w.smu.Lock()
+	hooks := append([]func(old, new CircuitState){}, w.hooks...)
+	w.smu.Unlock()
+	box, _ := w.downErr.Load().(errBox)
+	configured := func(c *Breaker) {
+		c.circuit.cfg = cfg
+		c.hooks = hooks
+		if box.err != nil {
+			c.downErr.Store(box)
+		}
+	}
+	opts := append(append([]Option{}, w.opts...), configured, WithName(name))
+	return NewDriverWithOptions(name, native, append(opts, extra...)...)
+}
+
+// newBreaker returns an unregistered Breaker for the native driver
+func newBreaker(native string, opts ...Option) *Breaker {
+	drv := configure(native, opts...)
+	drv.start()
+	return drv
+}
+
+// configure returns an unregistered Breaker for the native driver
+// with opts applied, but none of its background goroutines started
+func configure(native string, opts ...Option) *Breaker {
+	drv := &Breaker{
+		native:   native,
+		opts:     opts,
+		conns:    make(map[string]driver.Connector),
+		clock:    realClock{},
+		eventBuf: DefaultEventBuffer,
+		quit:     make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(drv)
+	}
+	drv.circuit.warmAt = drv.clock.Now()
+	return drv
+}
+
+// start starts the background goroutines of the options w was
+// configured with and publishes its expvars, see WithExpvar
+func (w *Breaker) start() {
+	if w.control != nil {
+		if w.control.Err() != nil {
+			// done already, there is nothing to wait for
+			w.lost.Store(true)
+		} else {
+			w.watchers.Add(1)
+			go w.watch(w.control)
+		}
+	}
+	if w.interval > 0 {
+		w.watchers.Add(1)
+		go w.autoProbe(w.interval)
+	}
+	if w.poll != nil && w.polling > 0 {
+		w.watchers.Add(1)
+		go w.pollHealth(w.poll, w.polling)
+	}
+	if w.vars != "" {
+		w.publish(w.vars)
+	}
+}
+
 // Breaker is an sql.Driver that can block access to the database
 type Breaker struct {
-	down   bool   // set true to disable access via this driver
-	native string // native sql driver
-	dbs    map[string]*sql.DB
+	down     atomic.Bool            // set true to disable access via this driver
+	forced   atomic.Pointer[string] // reason given to ForceOpen, nil unless forced
+	readOnly atomic.Bool            // set true to block writes via this driver
+	dryRun   bool                   // report read-only and category blocks without enforcing them
+	noReads  atomic.Bool            // set true by DisableReads
+	noWrites atomic.Bool            // set true by DisableWrites
+	banned   atomic.Uint32          // bit set of blocked statement categories
+	lost     atomic.Bool            // set true once the control context is done
+	sick     atomic.Bool            // set true while the health probe fails
+	windows  atomic.Int32           // number of maintenance windows in progress
+	gen      atomic.Uint64          // bumped as connections may have become gated
+	native   string                 // native sql driver
+	downErr  atomic.Value           // errBox returned instead of ErrDown when set
+	dsn      atomic.Pointer[string] // name last connected to, for auto probes
+	interval time.Duration          // time between auto probes, if set
+	empty    bool                   // read empty results while down
+	cooldown time.Duration          // how long Opens fail fast after one fails, if set
+	cooling  cooldowns              // names whose Opens fail fast
+	newOnly  bool                   // refuse only new connections while down by hand
+	health   string                 // query to check the database with, if set
+	vars     string                 // prefix of the expvar vars to publish, if set
+	opts     []Option               // options the breaker was made with
+	poll     func() bool            // external health signal, if set
+	polling  time.Duration          // time between calls to poll
+	pool     poolConfig             // pool settings of databases opened by WrapDB
+	slots    chan struct{}          // held by operations in flight, if limited
+	queue    bool                   // wait for a free slot rather than fail
+	mu       sync.RWMutex           // guards drv, conns, offline, stopped, dbs and reenable
+	drv      driver.Driver          // native driver, looked up on first use
+	conns    map[string]driver.Connector
+	offline  map[string]bool // names disabled by DisableName
+	stopped  bool            // set by Close
+	dbs      []*sql.DB       // databases opened by WrapDB
+	reenable chan struct{}   // closed to cancel the re-enable of DisableFor
+	quit     chan struct{}   // closed by Close to stop background goroutines
+	watchers watchGroup      // background goroutines to wait for on Close
+	circuit  circuit
+	stats    counters
+	named    namedStats // counters for each data source name
+	blocks   history    // recent blocked operations, see WithBlockedHistory
+	smu      sync.Mutex // guards last, hooks and ready
+	last     CircuitState
+	hooks    []func(old, new CircuitState)
+	ready    chan struct{} // closed once the state next changes to Closed
+	clock    Clock
+	emu      sync.RWMutex // guards events and closed
+	events   chan Event
+	eventBuf int
+	closed   bool // events has been closed
+	logger   *slog.Logger
+	name     string           // given by WithName, defaults to the driver name
+	failing  func(error) bool // reports errors that count toward tripping
+	timeout  time.Duration    // longest an exec or query may run, if set
+	control  context.Context  // takes the breaker down for good once done
+	fallback []*backend       // databases to read from while down, in order
+	attempts int              // times to try connecting before giving up
+	backoff  time.Duration    // wait between attempts to connect
+	draining atomic.Bool      // set true by Drain to refuse new work
+	imu      sync.Mutex       // guards inflight and idle
+	inflight int
+	idle     chan struct{} // closed when inflight drops to zero
+
+	// inspect vets statements before they run, if set
+	inspect func(ctx context.Context, query string) error
+
+	// rewrite changes data source names before they are used, if set
+	rewrite func(dsn string) (string, error)
+
+	// fingerprint normalizes statements to report them by, if set
+	fingerprint func(query string) string
+
+	// onBlocked is called for each operation refused, if set
+	onBlocked func(op, query, dsn string)
 }
 
 // Conn implements the sql.Driver.Conn interface
 type Conn struct {
-	c    driver.Conn
-	b    driver.ConnBeginTx
-	db   *sql.DB
-	down func() bool
+	c      driver.Conn
+	b      driver.ConnBeginTx
+	p      driver.Pinger
+	e      driver.ExecerContext
+	q      driver.QueryerContext
+	n      driver.NamedValueChecker
+	w      *Breaker
+	src    *backend
+	name   string
+	tally  *counters // counters for name
+	backup bool      // connected to a fallback database, or reading empty results
+	ro     bool      // in a read-only transaction
+	tx     bool      // in a transaction
 }
 
-// Disable allows changing if dribver is enabled
+// Disable allows changing if driver is enabled,
+// enabling the driver also ends any Drain.
+//
+// Calls that do not change the state are safe and otherwise no-ops,
+// state change hooks, events and logs only follow real transitions.
+// Either way a re-enable scheduled by DisableFor is cancelled.
 func (w *Breaker) Disable(off bool) {
-	w.down = off
+	w.mu.Lock()
+	w.cancelReenable()
+	changed := w.disable(off)
+	w.mu.Unlock()
+	if changed {
+		w.notify()
+	}
 }
 
-// Open satisfies the sql.Driver interface
-func (w *Breaker) Open(name string) (driver.Conn, error) {
-	if w.down {
-		return nil, ErrDown
+// disable sets whether the driver is disabled, returning true if that
+// changed it. The caller must hold the lock and notify of changes.
+func (w *Breaker) disable(off bool) bool {
+	if !off {
+		w.draining.Store(false)
 	}
-	db, ok := w.dbs[name]
-	if !ok {
-		var err error
-		db, err = sql.Open(w.native, name)
-		if err != nil {
-			return nil, err
+	return w.down.Swap(off) != off
+}
+
+// errBox lets atomic.Value hold errors of differing concrete types
+type errBox struct {
+	err error
+}
+
+// SetDownError sets the error returned while the breaker is down,
+// nil restores the default of a DownError wrapping ErrDown
+func (w *Breaker) SetDownError(err error) {
+	w.downErr.Store(errBox{err})
+}
+
+// errDown returns the error to use when the breaker is down for name
+func (w *Breaker) errDown(name string) error {
+	if box, ok := w.downErr.Load().(errBox); ok && box.err != nil {
+		return box.err
+	}
+	return &DownError{DSN: name, State: w.state()}
+}
+
+// DisableReads allows changing if reads are refused with the error returned
+// while down, while writes and transactions continue.
+//
+// A query is a read unless one of its statements is DDL or DML,
+// classified as described for StatementCategory.
+func (w *Breaker) DisableReads(off bool) {
+	w.noReads.Store(off)
+}
+
+// DisableWrites allows changing if writes and transactions other than
+// read-only ones are refused with the error returned while down, while
+// reads continue. Unlike SetReadOnly it reports the database as down
+// rather than read-only.
+//
+// A query is a write if any of its statements is DDL or DML,
+// classified as described for StatementCategory.
+func (w *Breaker) DisableWrites(off bool) {
+	w.noWrites.Store(off)
+}
+
+// SetReadOnly allows changing if writes are blocked while reads continue.
+//
+// Writes are told from reads as described for DisableWrites.
+func (w *Breaker) SetReadOnly(on bool) {
+	w.readOnly.Store(on)
+}
+
+// SetBlockedCategories blocks statements in the given categories with ErrBlocked,
+// replacing any set before, e.g. DDL during a schema migration. Calling it with
+// no categories unblocks them all.
+//
+// A query is blocked if any of its statements is in a blocked category, see
+// StatementCategory for how they are classified. Blocking Transaction also
+// blocks Begin and BeginTx.
+func (w *Breaker) SetBlockedCategories(cats ...StatementCategory) {
+	var bits uint32
+	for _, cat := range cats {
+		bits |= 1 << cat
+	}
+	w.banned.Store(bits)
+}
+
+// isBanned reports whether statements in cat are blocked
+func (w *Breaker) isBanned(cat StatementCategory) bool {
+	return w.banned.Load()&(1<<cat) != 0
+}
+
+// isBlocked reports whether any of the statements of query is in a blocked category
+func (w *Breaker) isBlocked(query string) bool {
+	bits := w.banned.Load()
+	return bits != 0 && bits&kinds(query) != 0
+}
+
+// IsDown reports whether the driver is currently disabled,
+// either by Disable, ForceOpen, a scheduled maintenance window
+// or the control context being done
+func (w *Breaker) IsDown() bool {
+	return w.down.Load() || w.isForced() || w.lost.Load() || w.sick.Load() || w.windows.Load() > 0
+}
+
+// DisableName allows changing if access to the database with the
+// given data source name is enabled, without affecting other names.
+//
+// Connections already open to that name are blocked as well.
+func (w *Breaker) DisableName(name string, off bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if off {
+		if w.offline == nil {
+			w.offline = make(map[string]bool)
 		}
-		w.dbs[name] = db
+		w.offline[name] = true
+		w.gen.Add(1)
+	} else {
+		delete(w.offline, name)
+	}
+}
+
+// IsNameDown reports whether the data source name is disabled,
+// either by DisableName or by the whole driver being disabled
+func (w *Breaker) IsNameDown(name string) bool {
+	return w.IsDown() || w.nameDown(name)
+}
+
+// unavailable reports whether the breaker is blocking access to name,
+// whether by hand or because the circuit has tripped
+func (w *Breaker) unavailable(name string) bool {
+	return w.IsNameDown(name) || w.tripped()
+}
+
+// Name returns the name of the breaker, see WithName
+func (w *Breaker) Name() string {
+	return w.name
+}
+
+// Names returns the sorted data source names the breaker has opened,
+// one for each sql.DB using it, for use with DisableName
+func (w *Breaker) Names() []string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	names := make([]string, 0, len(w.conns))
+	for name := range w.conns {
+		names = append(names, name)
 	}
+	sort.Strings(names)
+	return names
+}
 
-	c, err := db.Driver().Open(name)
+// nameDown reports whether name was disabled by DisableName
+func (w *Breaker) nameDown(name string) bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.offline[name]
+}
+
+// Open satisfies the sql.Driver interface
+func (w *Breaker) Open(name string) (driver.Conn, error) {
+	name, err := w.rewriteDSN(name)
 	if err != nil {
 		return nil, err
 	}
-	down := func() bool {
-		return w.down
+	w.mu.Lock()
+	drv, err := w.inner()
+	w.mu.Unlock()
+	if err != nil {
+		return nil, err
 	}
+	return w.connect(context.Background(), name, func() (driver.Conn, error) {
+		return drv.Open(name)
+	})
+}
+
+// connect gates dialing a new connection to name and wraps the result
+func (w *Breaker) connect(ctx context.Context, name string, dial func() (driver.Conn, error)) (driver.Conn, error) {
+	if w.isClosed() {
+		return nil, ErrClosed
+	}
+	w.dsn.Store(&name)
+	if err := ctx.Err(); err != nil {
+		// given up on already, the database is not to blame
+		return nil, err
+	}
+	if w.draining.Load() {
+		return nil, w.blocked(opOpen, name, w.errDown(name))
+	}
+	if w.IsNameDown(name) && !bypassed(ctx) {
+		return w.openFallback(name, w.errDown(name))
+	}
+	chilled, retest := w.chill(ctx, name)
+	if chilled {
+		return w.openFallback(name, w.errDown(name))
+	}
+	probe, err := w.acquire(ctx, name)
+	if err != nil && retest {
+		w.cooling.abort(name)
+	}
+	if err == ErrOverloaded || err != nil && err == ctx.Err() {
+		return nil, w.blocked(opOpen, name, err)
+	}
+	if err != nil {
+		return w.openFallback(name, err)
+	}
+	w.count(name, func(c *counters) { c.allowedOpens.Add(1) })
+	c, err := w.retry(ctx, name, dial)
+	c, err = checkConn(w.native, c, err)
+	w.settle(ctx, name, retest, err)
+	w.done(name, probe, w.outcome(ctx, ctx, err))
+	if err != nil {
+		return nil, err
+	}
+	return w.wrap(c, name, false), nil
+}
+
+// wrap returns a Conn gating c, a connection for name
+// to either the inner driver or the fallback database
+func (w *Breaker) wrap(c driver.Conn, name string, backup bool) *Conn {
 	b, _ := c.(driver.ConnBeginTx)
-	return &Conn{b: b, c: c, down: down}, nil
+	p, _ := c.(driver.Pinger)
+	e, _ := c.(driver.ExecerContext)
+	q, _ := c.(driver.QueryerContext)
+	n, _ := c.(driver.NamedValueChecker)
+	return &Conn{b: b, p: p, e: e, q: q, n: n, c: c, w: w, name: name, tally: w.named.of(name), backup: backup}
+}
+
+// checkConn returns c and err of opening a connection with the native
+// driver, or ErrNoConn if it returned neither, rather than let a nil
+// connection panic later
+func checkConn(native string, c driver.Conn, err error) (driver.Conn, error) {
+	if err == nil && c == nil {
+		return nil, fmt.Errorf("%s: %w", native, ErrNoConn)
+	}
+	return c, err
+}
+
+// inner returns the native driver, looking it up on first use.
+// The caller must hold the lock.
+func (w *Breaker) inner() (driver.Driver, error) {
+	if w.stopped {
+		return nil, ErrClosed
+	}
+	if w.drv != nil {
+		return w.drv, nil
+	}
+	drv, err := lookup(w.native)
+	if err != nil {
+		return nil, err
+	}
+	w.drv = drv
+	return drv, nil
+}
+
+// lookup returns the driver registered as native
+func lookup(native string) (driver.Driver, error) {
+	// the sql package only exposes registered drivers through a handle,
+	// which does not connect until it is used
+	db, err := sql.Open(native, "")
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	return db.Driver(), nil
+}
+
+// Close closes the inner connectors cached for each data source name that
+// implement io.Closer, and the Events channel, and stops the goroutine
+// watching the control context, after which new connections fail with ErrClosed.
+// It waits for the background goroutines to stop, other than those running
+// state change hooks or an automatic probe, so a hook may call it.
+//
+// Connections already handed out are not closed, the sql.DB using the
+// driver should be closed first. The sql package has no way to unregister
+// a driver, but the name the driver was registered under may be passed to
+// NewDriver again to bind it to a new Breaker.
+func (w *Breaker) Close() error {
+	w.mu.Lock()
+	if !w.stopped {
+		close(w.quit)
+	}
+	w.stopped = true
+	conns := w.conns
+	w.conns = make(map[string]driver.Connector)
+	w.mu.Unlock()
+
+	w.watchers.Wait()
+	w.CloseEvents()
+	var errs []error
+	for _, c := range conns {
+		if closer, ok := c.(io.Closer); ok {
+			errs = append(errs, closer.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// isClosed reports whether Close has been called
+func (w *Breaker) isClosed() bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.stopped
 }
 
 // Prepare satisfies the sql.driver.Conn interface
 func (c *Conn) Prepare(query string) (driver.Stmt, error) {
-	if c.down() {
-		return nil, ErrDown
+	return c.PrepareContext(context.Background(), query)
+}
+
+// PrepareContext satisfies the driver.ConnPrepareContext interface, passing
+// ctx on to the inner connection if it is a driver.ConnPrepareContext too.
+// The sql package prepares statements for connections that are not a
+// driver.ExecerContext or driver.QueryerContext, so their execs and
+// queries are canceled along with ctx while being prepared as well.
+func (c *Conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if err := c.w.screen(ctx, query); err != nil {
+		return nil, err
+	}
+	op := opQuery
+	if isWrite(query) {
+		op = opExec
+	}
+	if c.gated() && !bypassed(ctx) {
+		return nil, c.w.refuse(op, c.name, query, c.w.errDown(c.name))
+	}
+	// in dry-run mode the statement is reported when it runs instead
+	if c.w.isBlocked(query) && !c.w.dryRun {
+		return nil, c.w.refuse(op, c.name, query, ErrBlocked)
+	}
+	s, err := c.prepare(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return &stmt{s: s, c: c, query: query}, nil
+}
+
+// prepare delegates PrepareContext to the inner connection
+func (c *Conn) prepare(ctx context.Context, query string) (driver.Stmt, error) {
+	if preparer, ok := c.c.(driver.ConnPrepareContext); ok {
+		return preparer.PrepareContext(ctx, query)
+	}
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
 	}
 	return c.c.Prepare(query)
+}
+
+// screen passes query to the interceptor, if any, returning its verdict.
+// A panic in the interceptor refuses the statement.
+func (w *Breaker) screen(ctx context.Context, query string) (err error) {
+	if w.inspect == nil {
+		return nil
+	}
+	if perr := w.guard("query interceptor", func() { err = w.inspect(ctx, query) }); perr != nil {
+		return perr
+	}
+	return err
+}
+
+// down reports whether the breaker is blocking this connection,
+// connections to the fallback database are never blocked
+func (c *Conn) down() bool {
+	return !c.backup && c.w.unavailable(c.name)
+}
+
+// gated reports whether operations on the connection are refused as it is
+// down, which with WithBlockNewOnly is only once the circuit has tripped
+func (c *Conn) gated() bool {
+	if c.w.newOnly {
+		return !c.backup && c.w.tripped()
+	}
+	return c.down()
+}
+
+// stale reports whether the sql package should replace the connection
+// as the breaker has changed over to or back from the fallback database
+func (c *Conn) stale() bool {
+	return (len(c.w.fallback) > 0 || c.w.empty) && c.backup != c.w.unavailable(c.name)
+}
 
+// moved reports whether an operation about to start on the connection should
+// return driver.ErrBadConn, for the sql package to retry it on a new connection
+// as the breaker has changed over to or back from the fallback database since
+// this one was opened. Transactions can't leave their connection and a
+// bypassed operation may stay on the primary database, see WithBypass.
+func (c *Conn) moved(ctx context.Context) bool {
+	if c.tx || !c.stale() {
+		return false
+	}
+	return c.backup || !bypassed(ctx)
+}
+
+// acquire checks the circuit for an operation on the connection, see Breaker.acquire.
+//
+// The outcome of operations on the fallback database is not counted.
+func (c *Conn) acquire(ctx context.Context) (bool, error) {
+	if c.backup {
+		c.w.enter()
+		return false, nil
+	}
+	return c.w.acquire(ctx, c.name)
+}
+
+// done records the outcome of an operation allowed by acquire
+func (c *Conn) done(probe bool, err error) {
+	if c.backup {
+		c.w.leave()
+		return
+	}
+	c.w.done(c.name, probe, err)
+}
+
+// allow returns the error, if any, that should stop op from running query.
+//
+// When query is allowed to run its outcome must be reported with done.
+func (c *Conn) allow(ctx context.Context, op, query string) (probe bool, err error) {
+	if c.moved(ctx) {
+		return false, driver.ErrBadConn
+	}
+	write := isWrite(query)
+	if c.src != nil && c.src.ReadOnly && write {
+		return false, c.w.refuse(op, c.name, query, ErrReadOnly)
+	}
+	if c.gated() && !bypassed(ctx) || (c.backup || c.w.noWrites.Load()) && write || c.w.noReads.Load() && !write {
+		return false, c.w.refuse(op, c.name, query, c.w.errDown(c.name))
+	}
+	if c.ro && write {
+		return false, c.w.refuse(op, c.name, query, ErrReadOnly)
+	}
+	if c.w.readOnly.Load() && write {
+		if err := c.w.restrict(op, c.name, query, ErrReadOnly); err != nil {
+			return false, err
+		}
+	}
+	if c.w.isBlocked(query) {
+		if err := c.w.restrict(op, c.name, query, ErrBlocked); err != nil {
+			return false, err
+		}
+	}
+	if probe, err = c.acquire(ctx); err != nil {
+		return false, c.w.refuse(op, c.name, query, err)
+	}
+	return probe, nil
 }
 
 // Close invalidates and potentially stops any current
@@ -109,20 +768,289 @@ func (c *Conn) Close() error {
 // Begin starts and returns a new transaction.
 //
 // Deprecated: Drivers should implement ConnBeginTx instead (or additionally).
-func (c *Conn) Begin() (driver.Tx, error) {
-	if c.down() {
-		return nil, ErrDown
+func (c *Conn) Begin() (tx driver.Tx, err error) {
+	if c.moved(context.Background()) {
+		return nil, driver.ErrBadConn
+	}
+	if c.src != nil && c.src.ReadOnly {
+		return nil, c.w.blocked(opBegin, c.name, ErrReadOnly)
+	}
+	if c.gated() || c.backup || c.w.noWrites.Load() || c.w.draining.Load() {
+		return nil, c.w.blocked(opBegin, c.name, c.w.errDown(c.name))
+	}
+	if c.w.readOnly.Load() {
+		if err := c.w.restrict(opBegin, c.name, "", ErrReadOnly); err != nil {
+			return nil, err
+		}
 	}
-	return c.c.Begin()
+	if c.w.isBanned(Transaction) {
+		if err := c.w.restrict(opBegin, c.name, "", ErrBlocked); err != nil {
+			return nil, err
+		}
+	}
+	probe, err := c.acquire(context.Background())
+	if err != nil {
+		return nil, c.w.blocked(opBegin, c.name, err)
+	}
+	defer func() { c.done(probe, err) }()
+	t, err := c.c.Begin()
+	if err != nil {
+		return nil, err
+	}
+	wrapped := c.w.newTx(t)
+	wrapped.c = c
+	c.tx = true
+	return wrapped, nil
 }
 
 // BeginTx starts and returns a new transaction using a context.
-func (c *Conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
-	if c.down() {
-		return nil, ErrDown
+//
+// If the inner connection does not implement driver.ConnBeginTx the
+// transaction is started with Begin, unless options other than the
+// defaults are given, which return a ContextError.
+func (c *Conn) BeginTx(ctx context.Context, opts driver.TxOptions) (tx driver.Tx, err error) {
+	if c.moved(ctx) {
+		return nil, driver.ErrBadConn
+	}
+	if c.src != nil && c.src.ReadOnly && !opts.ReadOnly {
+		return nil, c.w.blocked(opBegin, c.name, ErrReadOnly)
+	}
+	if c.gated() && !bypassed(ctx) || (c.backup || c.w.noWrites.Load()) && !opts.ReadOnly || c.w.draining.Load() {
+		return nil, c.w.blocked(opBegin, c.name, c.w.errDown(c.name))
+	}
+	if c.w.readOnly.Load() && !opts.ReadOnly {
+		if err := c.w.restrict(opBegin, c.name, "", ErrReadOnly); err != nil {
+			return nil, err
+		}
+	}
+	if c.w.isBanned(Transaction) {
+		if err := c.w.restrict(opBegin, c.name, "", ErrBlocked); err != nil {
+			return nil, err
+		}
+	}
+	if c.b == nil && opts != (driver.TxOptions{}) {
+		return nil, &ContextError{DSN: c.name, Opts: opts}
+	}
+	probe, err := c.acquire(ctx)
+	if err != nil {
+		return nil, c.w.blocked(opBegin, c.name, err)
+	}
+	defer func() { c.done(probe, c.w.outcome(ctx, ctx, err)) }()
+	var t driver.Tx
+	if c.b != nil {
+		t, err = c.b.BeginTx(ctx, opts)
+	} else if err = ctx.Err(); err == nil {
+		t, err = c.c.Begin()
+	}
+	if err != nil {
+		return nil, err
+	}
+	wrapped := c.w.newTx(t)
+	wrapped.c = c
+	c.tx = true
+	// writes are refused until the transaction ends
+	c.ro = opts.ReadOnly
+	return wrapped, nil
+}
+
+// Ping verifies the connection to the database is still alive.
+//
+// If the inner connection does not implement driver.Pinger the
+// connection is assumed to be alive, matching the sql package.
+func (c *Conn) Ping(ctx context.Context) (err error) {
+	if c.gated() {
+		return c.w.errDown(c.name)
+	}
+	if c.p == nil {
+		return nil
+	}
+	probe, err := c.acquire(ctx)
+	if err != nil {
+		return err
 	}
-	if c.b == nil {
-		return nil, ErrContext
+	defer func() { c.done(probe, c.w.outcome(ctx, ctx, err)) }()
+	return c.p.Ping(ctx)
+}
+
+// Exec executes a query without a prepared statement.
+//
+// Deprecated: Drivers should implement ExecerContext instead.
+func (c *Conn) Exec(query string, args []driver.Value) (res driver.Result, err error) {
+	if err := c.w.screen(context.Background(), query); err != nil {
+		return nil, err
+	}
+	probe, err := c.allow(context.Background(), opExec, query)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { c.done(probe, err) }()
+	execer, ok := c.c.(driver.Execer)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	return execer.Exec(query, args)
+}
+
+// ExecContext executes a query without a prepared statement.
+//
+// If the inner connection supports neither ExecerContext nor Execer,
+// driver.ErrSkip is returned so the sql package falls back to Prepare.
+func (c *Conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (res driver.Result, err error) {
+	if _, ok := c.c.(driver.Execer); c.e == nil && !ok {
+		// the sql package prepares the statement instead, screening it then
+		return nil, driver.ErrSkip
+	}
+	if err := c.w.screen(ctx, query); err != nil {
+		return nil, err
+	}
+	probe, err := c.allow(ctx, opExec, query)
+	if err != nil {
+		return nil, err
+	}
+	caller := ctx
+	ctx, cancel := c.w.withTimeout(ctx)
+	if cancel != nil {
+		defer cancel()
+	}
+	defer func() { c.done(probe, c.w.outcome(caller, ctx, err)) }()
+	return c.result(ctx, query, args)
+}
+
+// result delegates ExecContext to the inner connection
+func (c *Conn) result(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if c.e != nil {
+		return c.e.ExecContext(ctx, query, args)
+	}
+	execer, ok := c.c.(driver.Execer)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	values, err := namedValues(args)
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+	return execer.Exec(query, values)
+}
+
+// Query executes a query without a prepared statement.
+//
+// Deprecated: Drivers should implement QueryerContext instead.
+func (c *Conn) Query(query string, args []driver.Value) (rows driver.Rows, err error) {
+	if err := c.w.screen(context.Background(), query); err != nil {
+		return nil, err
+	}
+	probe, err := c.allow(context.Background(), opQuery, query)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { c.done(probe, err) }()
+	queryer, ok := c.c.(driver.Queryer)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	return queryer.Query(query, args)
+}
+
+// QueryContext executes a query without a prepared statement.
+//
+// If the inner connection supports neither QueryerContext nor Queryer,
+// driver.ErrSkip is returned so the sql package falls back to Prepare.
+func (c *Conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (rows driver.Rows, err error) {
+	if _, ok := c.c.(driver.Queryer); c.q == nil && !ok {
+		// the sql package prepares the statement instead, screening it then
+		return nil, driver.ErrSkip
+	}
+	if err := c.w.screen(ctx, query); err != nil {
+		return nil, err
+	}
+	probe, err := c.allow(ctx, opQuery, query)
+	if err != nil {
+		return nil, err
+	}
+	caller := ctx
+	ctx, cancel := c.w.withTimeout(ctx)
+	defer func() { c.done(probe, c.w.outcome(caller, ctx, err)) }()
+	rows, err = c.rows(ctx, query, args)
+	return c.bind(ctx, rows, err, cancel)
+}
+
+// rows delegates QueryContext to the inner connection
+func (c *Conn) rows(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if c.q != nil {
+		return c.q.QueryContext(ctx, query, args)
+	}
+	queryer, ok := c.c.(driver.Queryer)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	values, err := namedValues(args)
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+	return queryer.Query(query, values)
+}
+
+// ResetSession is called by the sql package before a pooled connection is reused.
+//
+// The sql package only discards the connection if driver.ErrBadConn is
+// returned, so a disabled breaker relies on IsValid and the other Conn
+// methods to keep it from being used. With a fallback database or empty
+// reads configured, driver.ErrBadConn is returned to change over to or
+// back from them.
+func (c *Conn) ResetSession(ctx context.Context) error {
+	if c.stale() {
+		return driver.ErrBadConn
+	}
+	if c.gated() && !bypassed(ctx) {
+		return c.w.errDown(c.name)
+	}
+	if resetter, ok := c.c.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+// IsValid is called by the sql package before returning a connection to
+// the pool, a connection that is down is discarded rather than reused.
+func (c *Conn) IsValid() bool {
+	if c.down() || c.stale() {
+		return false
+	}
+	if validator, ok := c.c.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+// CheckNamedValue lets the inner connection validate and convert arguments.
+//
+// If the inner connection is not a driver.NamedValueChecker, driver.ErrSkip
+// is returned so the sql package applies its default conversions.
+func (c *Conn) CheckNamedValue(nv *driver.NamedValue) error {
+	if c.n == nil {
+		return driver.ErrSkip
+	}
+	return c.n.CheckNamedValue(nv)
+}
+
+// namedValues converts args for drivers that only support positional values
+func namedValues(named []driver.NamedValue) ([]driver.Value, error) {
+	args := make([]driver.Value, len(named))
+	for i, arg := range named {
+		if len(arg.Name) > 0 {
+			return nil, fmt.Errorf("dbreaker: driver does not support the use of Named Parameters")
+		}
+		args[i] = arg.Value
 	}
-	return c.b.BeginTx(ctx, opts)
+	return args, nil
 }
@@ -3,7 +3,15 @@ package dbreaker
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -119,3 +127,1011 @@ func TestBreaker(t *testing.T) {
 		t.Fatal("read error:", err)
 	}
 }
+
+func TestDisableRace(t *testing.T) {
+	const (
+		driver  = "wrapper-race"
+		create  = "create table if not exists users (id integer primary key, first_name text, last_name text)"
+		workers = 8
+		loops   = 50
+	)
+	breaker, err := NewDriver(driver, "sqlite3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open(driver, filepath.Join(t.TempDir(), "race.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(create); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	go func() {
+		for off := true; ; off = !off {
+			select {
+			case <-done:
+				return
+			default:
+				breaker.Disable(off)
+			}
+		}
+	}()
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < loops; j++ {
+				// errors are expected while the breaker flaps
+				read(db)
+			}
+		}()
+	}
+	wg.Wait()
+	close(done)
+
+	breaker.Disable(false)
+	if err := read(db); err != nil {
+		t.Fatal("read error:", err)
+	}
+}
+
+func TestOpenConcurrentNames(t *testing.T) {
+	const (
+		driver  = "wrapper-names"
+		workers = 16
+	)
+	if _, err := NewDriver(driver, "sqlite3"); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*2)
+	for i := 0; i < workers*2; i++ {
+		wg.Add(1)
+		// every DSN is opened by two goroutines to exercise the double-check
+		go func(i int) {
+			defer wg.Done()
+			db, err := sql.Open(driver, filepath.Join(dir, fmt.Sprintf("db%d.db", i%workers)))
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer db.Close()
+			errs <- db.Ping()
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestNativeNotRegistered(t *testing.T) {
+	const wrapper = "wrapper-unregistered"
+	_, err := NewDriver(wrapper, "sqlite-typo")
+	if err == nil || !strings.Contains(err.Error(), `"sqlite-typo" is not registered`) {
+		t.Fatalf("expected an unregistered native driver error but got: %v", err)
+	}
+	for _, d := range sql.Drivers() {
+		if d == wrapper {
+			t.Fatal("expected the wrapper not to be registered")
+		}
+	}
+}
+
+func TestIsDown(t *testing.T) {
+	breaker, err := NewDriver("wrapper-isdown", "sqlite3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if breaker.IsDown() {
+		t.Fatal("new driver should not be down")
+	}
+	breaker.Disable(true)
+	if !breaker.IsDown() {
+		t.Fatal("expected driver to be down after Disable(true)")
+	}
+	breaker.Disable(false)
+	if breaker.IsDown() {
+		t.Fatal("expected driver to be up after Disable(false)")
+	}
+}
+
+func TestPing(t *testing.T) {
+	const driver = "wrapper-ping"
+	breaker, err := NewDriver(driver, "sqlite3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open(driver, filepath.Join(t.TempDir(), "ping.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if err := db.Ping(); err != nil {
+		t.Fatal("ping failed:", err)
+	}
+	breaker.Disable(true)
+	if err := db.PingContext(context.Background()); !errors.Is(err, ErrDown) {
+		t.Fatalf("expected %v but got: %v", ErrDown, err)
+	}
+	breaker.Disable(false)
+	if err := db.Ping(); err != nil {
+		t.Fatal("ping failed:", err)
+	}
+}
+
+func TestExecContext(t *testing.T) {
+	const (
+		driver = "wrapper-exec"
+		create = "create table if not exists users (id integer primary key, first_name text, last_name text)"
+		insert = "insert into users (first_name, last_name) values(?, ?)"
+	)
+	ctx := context.Background()
+	breaker, err := NewDriver(driver, "sqlite3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open(driver, filepath.Join(t.TempDir(), "exec.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// pin a single connection so the gating is done by Conn, not Open
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, create); err != nil {
+		t.Fatal("exec fail:", err)
+	}
+	if r, err := conn.ExecContext(ctx, insert, "dee dee", "ramone"); err != nil {
+		t.Fatal("exec fail:", err)
+	} else if cnt, _ := r.RowsAffected(); cnt != 1 {
+		t.Fatalf("expected row count of 1 but got: %d", cnt)
+	}
+
+	breaker.Disable(true)
+	if _, err := conn.ExecContext(ctx, insert, "tommy", "ramone"); !errors.Is(err, ErrDown) {
+		t.Fatalf("expected %v but got: %v", ErrDown, err)
+	}
+	breaker.Disable(false)
+	if _, err := conn.ExecContext(ctx, insert, "tommy", "ramone"); err != nil {
+		t.Fatal("exec fail:", err)
+	}
+}
+
+func TestQueryContext(t *testing.T) {
+	const (
+		driver = "wrapper-query"
+		create = "create table if not exists users (id integer primary key, first_name text, last_name text)"
+		insert = "insert into users (first_name, last_name) values('joey','ramone')"
+		query  = "select first_name from users where last_name = ?"
+	)
+	ctx := context.Background()
+	breaker, err := NewDriver(driver, "sqlite3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open(driver, filepath.Join(t.TempDir(), "query.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	for _, q := range []string{create, insert} {
+		if _, err := conn.ExecContext(ctx, q); err != nil {
+			t.Fatal("exec fail:", err)
+		}
+	}
+
+	var first string
+	if err := conn.QueryRowContext(ctx, query, "ramone").Scan(&first); err != nil {
+		t.Fatal("query fail:", err)
+	}
+	if first != "joey" {
+		t.Fatalf("expected joey but got: %q", first)
+	}
+
+	breaker.Disable(true)
+	if _, err := conn.QueryContext(ctx, query, "ramone"); !errors.Is(err, ErrDown) {
+		t.Fatalf("expected %v but got: %v", ErrDown, err)
+	}
+	breaker.Disable(false)
+	if err := conn.QueryRowContext(ctx, query, "ramone").Scan(&first); err != nil {
+		t.Fatal("query fail:", err)
+	}
+}
+
+func TestCheckNamedValue(t *testing.T) {
+	const driver = "wrapper-checker"
+	mock, native := newMock()
+	if _, err := NewDriver(driver, native); err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open(driver, "checker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// the default converter rejects point, so this only works if the
+	// wrapper defers to the mock's CheckNamedValue
+	if _, err := db.Exec("insert into points values(?)", point{1, 2}); err != nil {
+		t.Fatal("exec fail:", err)
+	}
+	execs := mock.Execs()
+	if len(execs) != 1 {
+		t.Fatalf("expected 1 exec but got: %d", len(execs))
+	}
+	const expect = "insert into points values(?)[{ 1 (1,2)}]"
+	if execs[0] != expect {
+		t.Fatalf("expected %q but got: %q", expect, execs[0])
+	}
+}
+
+func TestCheckNamedValueDefaults(t *testing.T) {
+	const wrapper = "wrapper-checker-defaults"
+	mock, native := newLegacyMock()
+	if _, err := NewDriver(wrapper, native); err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open(wrapper, "checker-defaults")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// without a checker of the inner connection's own, the sql package
+	// converts arguments as it would for the inner driver
+	const insert = "insert into files values(?, ?, ?, ?)"
+	at := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	if _, err := db.Exec(insert, at, []byte("blob"), sql.NullString{String: "name", Valid: true}, sql.NullInt64{}); err != nil {
+		t.Fatal("exec fail:", err)
+	}
+	execs := mock.Execs()
+	if len(execs) != 1 {
+		t.Fatalf("expected 1 exec but got: %d", len(execs))
+	}
+	expect := fmt.Sprint(insert, []driver.NamedValue{
+		{Ordinal: 1, Value: at},
+		{Ordinal: 2, Value: []byte("blob")},
+		{Ordinal: 3, Value: "name"},
+		{Ordinal: 4, Value: nil},
+	})
+	if execs[0] != expect {
+		t.Fatalf("expected %q but got: %q", expect, execs[0])
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	const (
+		driver = "wrapper-readonly"
+		create = "create table if not exists users (id integer primary key, first_name text, last_name text)"
+		insert = "insert into users (first_name, last_name) values('joey','ramone')"
+	)
+	ctx := context.Background()
+	breaker, err := NewDriver(driver, "sqlite3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open(driver, filepath.Join(t.TempDir(), "readonly.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.ExecContext(ctx, create); err != nil {
+		t.Fatal("exec fail:", err)
+	}
+	stmt, err := db.PrepareContext(ctx, insert)
+	if err != nil {
+		t.Fatal("prepare fail:", err)
+	}
+	defer stmt.Close()
+
+	breaker.(*Breaker).SetReadOnly(true)
+	if _, err := db.ExecContext(ctx, insert); err != ErrReadOnly {
+		t.Fatalf("expected %v but got: %v", ErrReadOnly, err)
+	}
+	if _, err := stmt.ExecContext(ctx); err != ErrReadOnly {
+		t.Fatalf("expected %v but got: %v", ErrReadOnly, err)
+	}
+	if _, err := db.BeginTx(ctx, nil); err != ErrReadOnly {
+		t.Fatalf("expected %v but got: %v", ErrReadOnly, err)
+	}
+	if err := read(db); err != nil {
+		t.Fatal("read error:", err)
+	}
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		t.Fatal("read-only tx fail:", err)
+	}
+	tx.Rollback()
+
+	breaker.(*Breaker).SetReadOnly(false)
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		t.Fatal("exec fail:", err)
+	}
+}
+
+func TestSetBlockedCategories(t *testing.T) {
+	const wrapper = "wrapper-categories"
+	ctx := context.Background()
+	mock, native := newMock()
+	drv, err := NewDriver(wrapper, native)
+	if err != nil {
+		t.Fatal(err)
+	}
+	breaker := drv.(*Breaker)
+	db, err := sql.Open(wrapper, "categories")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	breaker.SetBlockedCategories(DDL)
+	if _, err := db.ExecContext(ctx, "create table t (id int)"); err != ErrBlocked {
+		t.Fatalf("expected %v but got: %v", ErrBlocked, err)
+	}
+	if _, err := db.PrepareContext(ctx, "drop table t"); err != ErrBlocked {
+		t.Fatalf("expected %v preparing but got: %v", ErrBlocked, err)
+	}
+	for _, query := range []string{
+		"select 1; drop table users",
+		"insert into t values(1);\n-- cleanup\ntruncate t",
+		`select '\'; drop table users; --'`,
+	} {
+		if _, err := db.ExecContext(ctx, query); err != ErrBlocked {
+			t.Fatalf("expected %v for %q but got: %v", ErrBlocked, query, err)
+		}
+	}
+	if _, err := db.ExecContext(ctx, "insert into t values(1)"); err != nil {
+		t.Fatal("exec fail:", err)
+	}
+	rows, err := db.QueryContext(ctx, "select id from t")
+	if err != nil {
+		t.Fatal("query fail:", err)
+	}
+	rows.Close()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal("begin fail:", err)
+	}
+	tx.Rollback()
+
+	breaker.SetBlockedCategories(Select, Transaction)
+	if _, err := db.QueryContext(ctx, "select id from t"); err != ErrBlocked {
+		t.Fatalf("expected %v but got: %v", ErrBlocked, err)
+	}
+	if _, err := db.BeginTx(ctx, nil); err != ErrBlocked {
+		t.Fatalf("expected %v but got: %v", ErrBlocked, err)
+	}
+
+	breaker.SetBlockedCategories()
+	if _, err := db.ExecContext(ctx, "create table t (id int)"); err != nil {
+		t.Fatal("exec fail:", err)
+	}
+	if n := len(mock.Execs()); n != 2 {
+		t.Fatalf("expected 2 statements executed but got: %d", n)
+	}
+}
+
+func TestSetDownError(t *testing.T) {
+	const driver = "wrapper-down-error"
+	errUnavailable := errors.New("503 service unavailable")
+	ctx := context.Background()
+	_, native := newMock()
+	drv, err := NewDriver(driver, native)
+	if err != nil {
+		t.Fatal(err)
+	}
+	breaker := drv.(*Breaker)
+	db, err := sql.Open(driver, "down-error")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	breaker.SetDownError(errUnavailable)
+	breaker.Disable(true)
+	if _, err := breaker.Open("down-error"); err != errUnavailable {
+		t.Fatalf("expected Open to return %v but got: %v", errUnavailable, err)
+	}
+	if _, err := conn.PrepareContext(ctx, "select 1"); err != errUnavailable {
+		t.Fatalf("expected Prepare to return %v but got: %v", errUnavailable, err)
+	}
+	if _, err := conn.ExecContext(ctx, "insert into users values(1)"); err != errUnavailable {
+		t.Fatalf("expected ExecContext to return %v but got: %v", errUnavailable, err)
+	}
+
+	// nil restores the default
+	breaker.SetDownError(nil)
+	if _, err := conn.ExecContext(ctx, "insert into users values(1)"); !errors.Is(err, ErrDown) {
+		t.Fatalf("expected ExecContext to return %v but got: %v", ErrDown, err)
+	}
+}
+
+func TestDisableName(t *testing.T) {
+	const driver = "wrapper-disable-name"
+	ctx := context.Background()
+	_, native := newMock()
+	drv, err := NewDriver(driver, native)
+	if err != nil {
+		t.Fatal(err)
+	}
+	breaker := drv.(*Breaker)
+
+	open := func(name string) *sql.DB {
+		t.Helper()
+		db, err := sql.Open(driver, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		if err := db.Ping(); err != nil {
+			t.Fatal(err)
+		}
+		return db
+	}
+	sales, users := open("sales"), open("users")
+
+	breaker.DisableName("sales", true)
+	if !breaker.IsNameDown("sales") || breaker.IsNameDown("users") {
+		t.Fatal("expected only sales to be down")
+	}
+	if breaker.IsDown() {
+		t.Fatal("disabling a name should not disable the driver")
+	}
+	// the pooled connection to sales is blocked as well as new ones
+	if _, err := sales.ExecContext(ctx, "insert into orders values(1)"); !errors.Is(err, ErrDown) {
+		t.Fatalf("expected %v but got: %v", ErrDown, err)
+	}
+	if _, err := breaker.Open("sales"); !errors.Is(err, ErrDown) {
+		t.Fatalf("expected %v but got: %v", ErrDown, err)
+	}
+	if _, err := users.ExecContext(ctx, "insert into users values(1)"); err != nil {
+		t.Fatal("exec fail:", err)
+	}
+
+	breaker.DisableName("sales", false)
+	if _, err := sales.ExecContext(ctx, "insert into orders values(1)"); err != nil {
+		t.Fatal("exec fail:", err)
+	}
+
+	// disabling the driver takes down every name
+	breaker.Disable(true)
+	if !breaker.IsNameDown("users") {
+		t.Fatal("expected users to be down with the driver disabled")
+	}
+}
+
+func TestNames(t *testing.T) {
+	const wrapper = "wrapper-names-list"
+	_, native := newMock()
+	drv, err := NewDriver(wrapper, native)
+	if err != nil {
+		t.Fatal(err)
+	}
+	breaker := drv.(*Breaker)
+	if names := breaker.Names(); len(names) != 0 {
+		t.Fatalf("expected no names but got: %q", names)
+	}
+	for _, name := range []string{"names2", "names1", "names2"} {
+		db, err := sql.Open(wrapper, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		if err := db.Ping(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	names := breaker.Names()
+	if len(names) != 2 || names[0] != "names1" || names[1] != "names2" {
+		t.Fatalf("expected names [names1 names2] but got: %q", names)
+	}
+	names[0] = "changed"
+	if breaker.Names()[0] != "names1" {
+		t.Fatal("expected Names to return a copy")
+	}
+}
+
+func TestResetSession(t *testing.T) {
+	const wrapper = "wrapper-reset"
+	ctx := context.Background()
+	mock, native := newMock()
+	drv, err := NewDriver(wrapper, native)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open(wrapper, "reset")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	// the second exec reuses the pooled connection, which resets it first
+	for i := 0; i < 2; i++ {
+		if _, err := db.ExecContext(ctx, "insert into users values(1)"); err != nil {
+			t.Fatal("exec fail:", err)
+		}
+	}
+	if n := atomic.LoadInt32(&mock.reset); n != 1 {
+		t.Fatalf("expected 1 session reset but got: %d", n)
+	}
+
+	conn, err := drv.Open("reset")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	resetter := conn.(driver.SessionResetter)
+	drv.Disable(true)
+	if err := resetter.ResetSession(ctx); !errors.Is(err, ErrDown) {
+		t.Fatalf("expected %v but got: %v", ErrDown, err)
+	}
+	drv.Disable(false)
+	if err := resetter.ResetSession(ctx); err != nil {
+		t.Fatal("reset fail:", err)
+	}
+}
+
+func TestIsValid(t *testing.T) {
+	const wrapper = "wrapper-valid"
+	ctx := context.Background()
+	mock, native := newMock()
+	drv, err := NewDriver(wrapper, native)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open(wrapper, "valid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err := db.ExecContext(ctx, "insert into users values(1)"); err != nil {
+		t.Fatal("exec fail:", err)
+	}
+	if n := db.Stats().Idle; n != 1 {
+		t.Fatalf("expected 1 idle connection but got: %d", n)
+	}
+
+	// the stale connection is discarded instead of going back to the pool
+	drv.Disable(true)
+	if _, err := db.ExecContext(ctx, "insert into users values(1)"); !errors.Is(err, ErrDown) {
+		t.Fatalf("expected %v but got: %v", ErrDown, err)
+	}
+	if n := db.Stats().OpenConnections; n != 0 {
+		t.Fatalf("expected no open connections but got: %d", n)
+	}
+
+	drv.Disable(false)
+	if _, err := db.ExecContext(ctx, "insert into users values(1)"); err != nil {
+		t.Fatal("exec fail:", err)
+	}
+	if n := atomic.LoadInt32(&mock.opens); n != 2 {
+		t.Fatalf("expected a fresh connection to be opened but got %d opens", n)
+	}
+}
+
+func TestClose(t *testing.T) {
+	const wrapper = "wrapper-close"
+	mock, native := newContextMock()
+	drv, err := NewDriver(wrapper, native)
+	if err != nil {
+		t.Fatal(err)
+	}
+	breaker := drv.(*Breaker)
+
+	for _, name := range []string{"close1", "close2"} {
+		if _, err := breaker.OpenConnector(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// looking up the native driver may open and close a connector of its own
+	closed := atomic.LoadInt32(&mock.closed)
+	if err := breaker.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&mock.closed) - closed; n != 2 {
+		t.Fatalf("expected 2 inner connectors closed but got: %d", n)
+	}
+	if len(breaker.conns) != 0 {
+		t.Fatalf("expected no cached connectors but got: %d", len(breaker.conns))
+	}
+	for _, name := range []string{"close1", "close3"} {
+		if _, err := breaker.Open(name); err != ErrClosed {
+			t.Fatalf("expected %v opening %s but got: %v", ErrClosed, name, err)
+		}
+		if _, err := breaker.OpenConnector(name); err != ErrClosed {
+			t.Fatalf("expected %v for a connector to %s but got: %v", ErrClosed, name, err)
+		}
+	}
+}
+
+func TestOpenReuse(t *testing.T) {
+	const wrapper = "wrapper-reuse"
+	ctx := context.Background()
+	mock, native := newMock()
+	if _, err := NewDriver(wrapper, native); err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open(wrapper, "reuse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	for i := 0; i < 5; i++ {
+		if _, err := db.ExecContext(ctx, "insert into users values(1)"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := atomic.LoadInt32(&mock.opens); n != 1 {
+		t.Fatalf("expected the pool to reuse 1 connection but got: %d", n)
+	}
+	if n := db.Stats().OpenConnections; n != 1 {
+		t.Fatalf("expected 1 open connection but got: %d", n)
+	}
+}
+
+func TestNewDriverReuseName(t *testing.T) {
+	const wrapper = "wrapper-reuse-name"
+	ctx := context.Background()
+	_, native := newMock()
+	first, err := NewDriver(wrapper, native)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewDriver(wrapper, native); err == nil {
+		t.Fatal("expected an error reusing the name of an open driver")
+	}
+	if err := first.(*Breaker).Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := NewDriver(wrapper, native)
+	if err != nil {
+		t.Fatalf("expected the name to be reusable after Close but got: %v", err)
+	}
+	db, err := sql.Open(wrapper, "reuse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.PingContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if db.Driver() != second {
+		t.Fatal("expected the name to be bound to the new driver")
+	}
+	second.Disable(true)
+	if _, err := db.ExecContext(ctx, "insert into users values(1)"); !errors.Is(err, ErrDown) {
+		t.Fatalf("expected %v from the new driver but got: %v", ErrDown, err)
+	}
+}
+
+func TestDownError(t *testing.T) {
+	const wrapper = "wrapper-typed-down"
+	_, native := newMock()
+	breaker, err := NewDriver(wrapper, native)
+	if err != nil {
+		t.Fatal(err)
+	}
+	breaker.Disable(true)
+
+	_, err = breaker.Open("inventory")
+	if !errors.Is(err, ErrDown) {
+		t.Fatalf("expected %v but got: %v", ErrDown, err)
+	}
+	var down *DownError
+	if !errors.As(err, &down) {
+		t.Fatalf("expected a *DownError but got: %T", err)
+	}
+	if down.DSN != "inventory" || down.State != Open {
+		t.Fatalf("expected DSN inventory in state %v but got: %+v", Open, down)
+	}
+	if msg := err.Error(); msg != "database is down (breaker open)" {
+		t.Fatalf("expected the message to give the state but got: %q", msg)
+	}
+
+	// a name disabled with the circuit closed doesn't blame the circuit
+	breaker.Disable(false)
+	breaker.(*Breaker).DisableName("inventory", true)
+	_, err = breaker.Open("inventory")
+	if !errors.As(err, &down) || down.State != Closed {
+		t.Fatalf("expected a *DownError in state %v but got: %v", Closed, err)
+	}
+	if msg := err.Error(); msg != ErrDown.Error() {
+		t.Fatalf("expected the message %q but got: %q", ErrDown, msg)
+	}
+
+	// a custom down error replaces it
+	errMaint := errors.New("down for maintenance")
+	breaker.(*Breaker).SetDownError(errMaint)
+	if _, err := breaker.Open("inventory"); err != errMaint {
+		t.Fatalf("expected %v but got: %v", errMaint, err)
+	}
+}
+
+func TestBeginTxLegacy(t *testing.T) {
+	ctx := context.Background()
+	mock, native := newMock()
+	breaker := newBreaker(native)
+	readOnly := driver.TxOptions{ReadOnly: true}
+	serial := driver.TxOptions{Isolation: driver.IsolationLevel(sql.LevelSerializable)}
+
+	// a driver with ConnBeginTx is given the options
+	conn := breaker.wrap(&mockConn{d: mock}, "modern", false)
+	for _, opts := range []driver.TxOptions{{}, readOnly, serial} {
+		tx, err := conn.BeginTx(ctx, opts)
+		if err != nil {
+			t.Fatalf("expected a transaction with options %+v but got: %v", opts, err)
+		}
+		tx.Rollback()
+	}
+
+	// one without falls back to Begin for the default options only
+	conn = breaker.wrap(legacyConn{c: &mockConn{d: mock}}, "legacy", false)
+	tx, err := conn.BeginTx(ctx, driver.TxOptions{})
+	if err != nil {
+		t.Fatalf("expected a transaction with the default options but got: %v", err)
+	}
+	tx.Rollback()
+	for _, opts := range []driver.TxOptions{readOnly, serial} {
+		_, err := conn.BeginTx(ctx, opts)
+		if !errors.Is(err, ErrContext) {
+			t.Fatalf("expected %v for options %+v but got: %v", ErrContext, opts, err)
+		}
+		var ctxErr *ContextError
+		if !errors.As(err, &ctxErr) || ctxErr.DSN != "legacy" || ctxErr.Opts != opts {
+			t.Fatalf("expected a ContextError for options %+v but got: %#v", opts, err)
+		}
+	}
+	if _, err := conn.BeginTx(ctx, serial); err.Error() != ErrContext.Error()+": transaction options isolation level Serializable" {
+		t.Fatalf("unexpected error message: %v", err)
+	}
+	if n := breaker.InFlight(); n != 0 {
+		t.Fatalf("expected nothing in flight but got: %d", n)
+	}
+
+	breaker.Disable(true)
+	if _, err := conn.BeginTx(ctx, driver.TxOptions{}); !errors.Is(err, ErrDown) {
+		t.Fatalf("expected %v but got: %v", ErrDown, err)
+	}
+}
+
+func TestDisableReadsWrites(t *testing.T) {
+	const wrapper = "wrapper-reads-writes"
+	ctx := context.Background()
+	_, native := newMock()
+	drv, err := NewDriver(wrapper, native)
+	if err != nil {
+		t.Fatal(err)
+	}
+	breaker := drv.(*Breaker)
+	db, err := sql.Open(wrapper, "reads-writes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	read := func() error {
+		rows, err := db.QueryContext(ctx, "select * from users")
+		if err == nil {
+			rows.Close()
+		}
+		return err
+	}
+	write := func() error {
+		_, err := db.ExecContext(ctx, "insert into users values(1)")
+		return err
+	}
+
+	breaker.DisableWrites(true)
+	if err := write(); !errors.Is(err, ErrDown) {
+		t.Fatalf("expected writes to fail with %v but got: %v", ErrDown, err)
+	}
+	if _, err := db.BeginTx(ctx, nil); !errors.Is(err, ErrDown) {
+		t.Fatalf("expected transactions to fail with %v but got: %v", ErrDown, err)
+	}
+	if err := read(); err != nil {
+		t.Fatalf("expected reads to continue but got: %v", err)
+	}
+	breaker.DisableWrites(false)
+
+	breaker.DisableReads(true)
+	if err := read(); !errors.Is(err, ErrDown) {
+		t.Fatalf("expected reads to fail with %v but got: %v", ErrDown, err)
+	}
+	if err := write(); err != nil {
+		t.Fatalf("expected writes to continue but got: %v", err)
+	}
+	breaker.DisableReads(false)
+	if err := read(); err != nil {
+		t.Fatalf("expected reads once enabled but got: %v", err)
+	}
+}
+
+func TestCloneWithOptions(t *testing.T) {
+	const (
+		wrapper = "wrapper-clone-source"
+		clone   = "wrapper-clone"
+	)
+	_, native := newMock()
+	_, replica := newMock()
+	drv, err := NewDriverWithOptions(wrapper, native, WithFailureThreshold(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	breaker := drv.(*Breaker)
+	var changes []string
+	breaker.OnStateChange(func(old, new CircuitState) {
+		changes = append(changes, new.String())
+	})
+
+	c, err := breaker.CloneWithOptions(clone, replica, WithResetTimeout(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cloned := c.(*Breaker)
+	if name := cloned.Name(); name != clone {
+		t.Fatalf("expected name %q but got: %q", clone, name)
+	}
+	cfg := cloned.circuit.config()
+	if cfg.Threshold != 2 || cfg.ResetTimeout != time.Minute {
+		t.Fatalf("expected the source's threshold and the extra reset timeout but got: %+v", cfg)
+	}
+	if _, err := breaker.CloneWithOptions(clone, replica); err == nil {
+		t.Fatal("expected an error cloning to a registered name")
+	}
+
+	// disabling the clone leaves the source as it was
+	cloned.Disable(true)
+	if breaker.IsDown() {
+		t.Fatal("expected the source to stay up")
+	}
+	if !cloned.IsDown() {
+		t.Fatal("expected the clone to be down")
+	}
+	db, err := sql.Open(wrapper, "clone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec("insert into users values(1)"); err != nil {
+		t.Fatalf("expected the source to allow writes but got: %v", err)
+	}
+	if len(changes) != 1 || changes[0] != "open" {
+		t.Fatalf("expected the copied hook to see the clone open but got: %v", changes)
+	}
+}
+
+func TestNilConn(t *testing.T) {
+	const wrapper = "wrapper-nil-conn"
+	native := newNilMock()
+	drv, err := NewDriver(wrapper, native)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open(wrapper, "nil-dsn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = db.Ping()
+	if !errors.Is(err, ErrNoConn) {
+		t.Fatalf("expected %v but got: %v", ErrNoConn, err)
+	}
+	if want := native + ": " + ErrNoConn.Error(); err.Error() != want {
+		t.Fatalf("expected error %q but got: %q", want, err)
+	}
+	if stats := drv.(*Breaker).Stats(); stats.AllowedOpens == 0 {
+		t.Fatalf("expected the open to reach the driver but got: %+v", stats)
+	}
+}
+
+func TestBlockNewOnly(t *testing.T) {
+	ctx := context.Background()
+	for _, tt := range []struct {
+		wrapper string
+		newOnly bool
+	}{
+		{"wrapper-block-all", false},
+		{"wrapper-block-new-only", true},
+	} {
+		_, native := newMock()
+		drv, err := NewDriverWithOptions(tt.wrapper, native, WithBlockNewOnly(tt.newOnly))
+		if err != nil {
+			t.Fatal(err)
+		}
+		db, err := sql.Open(tt.wrapper, "block-new")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		conn, err := db.Conn(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		drv.Disable(true)
+		_, execErr := conn.ExecContext(ctx, "insert into users values(1)")
+		rows, queryErr := conn.QueryContext(ctx, "select * from users")
+		if queryErr == nil {
+			rows.Close()
+		}
+		if tt.newOnly && (execErr != nil || queryErr != nil) {
+			t.Fatalf("%s: expected the open connection to keep working but got: %v, %v", tt.wrapper, execErr, queryErr)
+		}
+		if !tt.newOnly && (!errors.Is(execErr, ErrDown) || !errors.Is(queryErr, ErrDown)) {
+			t.Fatalf("%s: expected %v on the open connection but got: %v, %v", tt.wrapper, ErrDown, execErr, queryErr)
+		}
+		if _, err := db.Conn(ctx); !errors.Is(err, ErrDown) {
+			t.Fatalf("%s: expected %v for a new connection but got: %v", tt.wrapper, ErrDown, err)
+		}
+
+		// the connection is discarded rather than pooled once released
+		conn.Close()
+		if n := db.Stats().OpenConnections; n != 0 {
+			t.Fatalf("%s: expected no open connections but got: %d", tt.wrapper, n)
+		}
+		if _, err := db.ExecContext(ctx, "insert into users values(2)"); !errors.Is(err, ErrDown) {
+			t.Fatalf("%s: expected %v but got: %v", tt.wrapper, ErrDown, err)
+		}
+	}
+}
+
+func TestBlockNewOnlyTripped(t *testing.T) {
+	const wrapper = "wrapper-block-new-only-tripped"
+	ctx := context.Background()
+	mock, native := newMock()
+	if _, err := NewDriverWithOptions(wrapper, native, WithBlockNewOnly(true), WithFailureThreshold(1)); err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open(wrapper, "block-new-tripped")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	mock.Fail(errMock)
+	if _, err := conn.ExecContext(ctx, "insert into users values(1)"); err != errMock {
+		t.Fatalf("expected %v but got: %v", errMock, err)
+	}
+	mock.Fail(nil)
+	if _, err := conn.ExecContext(ctx, "insert into users values(2)"); !errors.Is(err, ErrDown) {
+		t.Fatalf("expected %v on the open connection once tripped but got: %v", ErrDown, err)
+	}
+}
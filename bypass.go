@@ -0,0 +1,31 @@
+package dbreaker
+
+import "context"
+
+// bypassKey is the context key marking operations that skip the down check
+type bypassKey struct{}
+
+// WithBypass returns a copy of ctx that lets operations using it through the
+// breaker while it is down, to repair the database from admin tooling.
+//
+// This is dangerous: bypassed operations reach a database that has been
+// disabled or has tripped the breaker, adding load to it when it is least
+// able to take it. Use it only for the few statements needed to fix an
+// outage, never for regular traffic.
+//
+// The bypass only applies to context-aware operations, ExecContext,
+// QueryContext, PrepareContext and BeginTx, and to the connections opened
+// for them. A transaction begun with it must be given it again for each of
+// its statements. Read-only mode, disabled reads or writes and blocked
+// statement categories still apply, as does WithMaxConcurrent, and outcomes
+// of bypassed operations still count toward the failure policy while the
+// circuit is closed.
+func WithBypass(ctx context.Context) context.Context {
+	return context.WithValue(ctx, bypassKey{}, true)
+}
+
+// bypassed reports whether ctx was returned by WithBypass
+func bypassed(ctx context.Context) bool {
+	on, _ := ctx.Value(bypassKey{}).(bool)
+	return on
+}
@@ -0,0 +1,126 @@
+package dbreaker
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/paulstuart/dbreaker/dbreakertest"
+)
+
+func TestWithBypass(t *testing.T) {
+	const wrapper = "wrapper-bypass"
+	ctx := context.Background()
+	admin := WithBypass(ctx)
+	fake, native := dbreakertest.Register()
+	drv, err := NewDriverWithOptions(wrapper, native, WithFailureThreshold(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	breaker := drv.(*Breaker)
+	db, err := sql.Open(wrapper, "bypass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	check := func(when string) {
+		t.Helper()
+		if _, err := db.ExecContext(ctx, "insert into users values(1)"); !errors.Is(err, ErrDown) {
+			t.Fatalf("expected %v for a normal exec %s but got: %v", ErrDown, when, err)
+		}
+		if _, err := db.QueryContext(ctx, "select * from users"); !errors.Is(err, ErrDown) {
+			t.Fatalf("expected %v for a normal query %s but got: %v", ErrDown, when, err)
+		}
+		if _, err := db.BeginTx(ctx, nil); !errors.Is(err, ErrDown) {
+			t.Fatalf("expected %v for a normal transaction %s but got: %v", ErrDown, when, err)
+		}
+
+		if _, err := db.ExecContext(admin, "insert into users values(1)"); err != nil {
+			t.Fatalf("expected a bypassed exec to succeed %s but got: %v", when, err)
+		}
+		rows, err := db.QueryContext(admin, "select * from users")
+		if err != nil {
+			t.Fatalf("expected a bypassed query to succeed %s but got: %v", when, err)
+		}
+		for rows.Next() {
+		}
+		if err := rows.Close(); err != nil {
+			t.Fatal(err)
+		}
+		tx, err := db.BeginTx(admin, nil)
+		if err != nil {
+			t.Fatalf("expected a bypassed transaction to succeed %s but got: %v", when, err)
+		}
+		if _, err := tx.ExecContext(admin, "update users set id=2"); err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	breaker.Disable(true)
+	check("while disabled")
+	breaker.Disable(false)
+
+	fake.Fail(dbreakertest.Exec, errMock)
+	if _, err := db.ExecContext(ctx, "insert into users values(1)"); err != errMock {
+		t.Fatalf("expected %v but got: %v", errMock, err)
+	}
+	if state := breaker.State(); state != Open {
+		t.Fatalf("expected state %v but got: %v", Open, state)
+	}
+	fake.Fail(dbreakertest.Exec, nil)
+	check("while tripped")
+}
+
+func TestWithBypassLegacy(t *testing.T) {
+	const wrapper = "wrapper-bypass-legacy"
+	ctx := context.Background()
+	admin := WithBypass(ctx)
+	_, native := newLegacyMock()
+	drv, err := NewDriver(wrapper, native)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open(wrapper, "bypass-legacy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.Ping(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the sql package prepares execs and queries for connections without
+	// ExecerContext and QueryerContext, which must be bypassed as well
+	drv.Disable(true)
+	if _, err := db.ExecContext(ctx, "insert into users values(1)"); !errors.Is(err, ErrDown) {
+		t.Fatalf("expected %v for a normal exec but got: %v", ErrDown, err)
+	}
+	if _, err := db.ExecContext(admin, "insert into users values(1)"); err != nil {
+		t.Fatalf("expected a bypassed exec to succeed but got: %v", err)
+	}
+	rows, err := db.QueryContext(admin, "select * from users")
+	if err != nil {
+		t.Fatalf("expected a bypassed query to succeed but got: %v", err)
+	}
+	rows.Close()
+	tx, err := db.BeginTx(admin, nil)
+	if err != nil {
+		t.Fatalf("expected a bypassed transaction to succeed but got: %v", err)
+	}
+	defer tx.Rollback()
+	stmt, err := tx.PrepareContext(admin, "update users set id=2")
+	if err != nil {
+		t.Fatalf("expected a bypassed prepare to succeed but got: %v", err)
+	}
+	if _, err := stmt.ExecContext(admin); err != nil {
+		t.Fatalf("expected a bypassed statement to run but got: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,490 @@
+package dbreaker
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// DefaultResetTimeout is how long a tripped breaker stays open when
+// AutoTrip.ResetTimeout is not set
+const DefaultResetTimeout = 30 * time.Second
+
+// CircuitState is the state of the circuit breaker
+type CircuitState int
+
+const (
+	// Closed lets operations through to the database
+	Closed CircuitState = iota
+	// Open blocks operations from reaching the database
+	Open
+	// HalfOpen lets a probe through to test if the database has recovered
+	HalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("CircuitState(%d)", int(s))
+}
+
+// AutoTrip configures the breaker to open automatically after repeated failures
+type AutoTrip struct {
+	// Threshold is the number of consecutive failures that trips the breaker,
+	// zero disables tripping on consecutive failures
+	Threshold int
+
+	// ResetTimeout is how long the breaker stays open before moving to half-open,
+	// zero uses DefaultResetTimeout
+	ResetTimeout time.Duration
+
+	// MaxResetTimeout, if set, doubles the reset timeout for each trip in a row,
+	// up to MaxResetTimeout. Trips are in a row while probes fail, or if the
+	// breaker trips again within MaxResetTimeout of closing, while staying
+	// closed for longer starts over from ResetTimeout.
+	MaxResetTimeout time.Duration
+
+	// MaxProbes is the number of operations let through at once while half-open,
+	// zero allows a single probe
+	MaxProbes int
+
+	// Successes is the number of consecutive successful probes needed to
+	// close the breaker while half-open, zero closes it on the first
+	Successes int
+
+	// FailureRate trips the breaker when the ratio of failed operations
+	// over Window exceeds it, as an alternative or in addition to Threshold.
+	// Zero disables tripping on the failure rate.
+	FailureRate float64
+
+	// Window is how far back the failure rate is measured,
+	// zero uses DefaultRateWindow
+	Window time.Duration
+
+	// MinRequests is the number of operations needed within Window
+	// before the failure rate can trip the breaker
+	MinRequests int
+
+	// Warmup keeps the breaker from tripping for this long after it is
+	// created or reset by ForceClose. Failures are still counted, so the
+	// breaker trips on the first failure after the warmup if they are
+	// past Threshold or FailureRate by then.
+	Warmup time.Duration
+}
+
+// enabled reports whether the breaker trips automatically
+func (cfg AutoTrip) enabled() bool {
+	return cfg.Threshold > 0 || cfg.FailureRate > 0
+}
+
+// circuit tracks failures of the inner driver and trips open when they pile up
+type circuit struct {
+	mu       sync.Mutex
+	cfg      AutoTrip
+	state    CircuitState
+	failures int       // consecutive failures while closed
+	openedAt time.Time // when the circuit last tripped
+	probes   int       // probes in flight while half-open
+	passed   int       // consecutive successful probes while half-open
+	outcomes ring      // recent outcomes while closed, for the failure rate
+	streak   int       // trips in a row, doubling the reset timeout
+	closedAt time.Time // when the circuit last closed after tripping
+	warmAt   time.Time // when the warmup began
+}
+
+// configure replaces the auto-trip settings, resetting the circuit if disabled
+func (c *circuit) configure(cfg AutoTrip) {
+	c.reconfigure(func(old *AutoTrip) { *old = cfg })
+}
+
+// reconfigure changes the auto-trip settings with fn, resetting the circuit if disabled
+func (c *circuit) reconfigure(fn func(cfg *AutoTrip)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fn(&c.cfg)
+	if !c.cfg.enabled() {
+		c.state = Closed
+		c.failures = 0
+		c.outcomes = ring{}
+		c.streak = 0
+	}
+}
+
+// config returns the auto-trip settings
+func (c *circuit) config() AutoTrip {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cfg
+}
+
+// reset closes the circuit, forgetting past failures, and starts the warmup at now
+func (c *circuit) reset(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.warmAt = now
+	c.state = Closed
+	c.failures = 0
+	c.passed = 0
+	c.outcomes = ring{}
+	c.streak = 0
+	c.closedAt = time.Time{}
+}
+
+// clear forgets past failures, leaving the state as is
+func (c *circuit) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.failures = 0
+	c.outcomes = ring{}
+}
+
+// current returns the state as of now
+func (c *circuit) current(now time.Time) CircuitState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.update(now)
+	return c.state
+}
+
+// update moves an open circuit to half-open once the reset timeout passes.
+// The caller must hold the lock.
+func (c *circuit) update(now time.Time) {
+	if c.state != Open {
+		return
+	}
+	if now.Sub(c.openedAt) >= c.timeout() {
+		c.state = HalfOpen
+	}
+}
+
+// timeout returns how long the circuit stays open for having tripped,
+// backing off for trips in a row. The caller must hold the lock.
+func (c *circuit) timeout() time.Duration {
+	timeout := c.cfg.ResetTimeout
+	if timeout <= 0 {
+		timeout = DefaultResetTimeout
+	}
+	for i := 0; i < c.streak && timeout < c.cfg.MaxResetTimeout; i++ {
+		timeout = min(2*timeout, c.cfg.MaxResetTimeout)
+	}
+	return timeout
+}
+
+// tripped reports if the circuit is open, for operations that don't report results
+func (c *circuit) tripped(now time.Time) bool {
+	return c.current(now) == Open
+}
+
+// acquire returns ErrDown if an operation may not proceed.
+//
+// If the operation is let through as a half-open probe, probe is true
+// and its outcome decides whether the circuit closes again.
+// Every successful acquire must be paired with a call to done.
+func (c *circuit) acquire(now time.Time) (probe bool, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.update(now)
+	switch c.state {
+	case Open:
+		return false, ErrDown
+	case HalfOpen:
+		if c.probes >= max(c.cfg.MaxProbes, 1) {
+			return false, ErrDown
+		}
+		c.probes++
+		return true, nil
+	}
+	return false, nil
+}
+
+// done records the outcome of an operation allowed by acquire,
+// returning true if the outcome tripped the circuit
+func (c *circuit) done(probe bool, err error, now time.Time) (tripped bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if probe {
+		c.probes--
+	}
+	if err == driver.ErrSkip || err == errNeutral {
+		// not a result, the sql package will retry another way,
+		// or not one the database is to blame for, such as errors
+		// the failure classifier rejects, so neither a success nor
+		// a failure
+		return false
+	}
+	if c.cfg.FailureRate > 0 && c.state == Closed {
+		c.outcomes.add(now, c.cfg.window(), err != nil)
+	}
+	if err == nil {
+		c.failures = 0
+		if probe && c.state == HalfOpen {
+			c.passed++
+			if c.passed >= max(c.cfg.Successes, 1) {
+				c.state = Closed
+				c.passed = 0
+				c.closedAt = now
+			}
+		}
+		return false
+	}
+	if !c.cfg.enabled() {
+		return false
+	}
+	switch c.state {
+	case Closed:
+		c.failures++
+		if now.Sub(c.warmAt) < c.cfg.Warmup {
+			return false
+		}
+		if c.cfg.Threshold > 0 && c.failures >= c.cfg.Threshold || c.outcomes.exceeded(now, c.cfg) {
+			c.trip(now)
+			return true
+		}
+	case HalfOpen:
+		if probe {
+			c.trip(now)
+			return true
+		}
+	}
+	return false
+}
+
+// trip opens the circuit. The caller must hold the lock.
+func (c *circuit) trip(now time.Time) {
+	switch {
+	case c.state == HalfOpen, !c.closedAt.IsZero() && now.Sub(c.closedAt) < c.cfg.MaxResetTimeout:
+		c.streak++
+	default:
+		c.streak = 0
+	}
+	c.state = Open
+	c.openedAt = now
+	c.failures = 0
+	c.passed = 0
+	c.outcomes = ring{}
+}
+
+// SetAutoTrip configures the breaker to open automatically after
+// cfg.Threshold consecutive errors from the inner driver's Open,
+// Exec, Query, Begin, or Ping operations, or once the rate of
+// errors over cfg.Window exceeds cfg.FailureRate.
+//
+// Once open, operations return ErrDown until cfg.ResetTimeout has passed.
+// If cfg.MaxResetTimeout is set, the timeout doubles for each trip in a
+// row, up to that limit, as described for AutoTrip. Then up to cfg.MaxProbes
+// probes are let through at once. cfg.Successes of them succeeding in a
+// row closes the breaker, while one failing opens it again for another
+// timeout.
+func (w *Breaker) SetAutoTrip(cfg AutoTrip) {
+	w.circuit.configure(cfg)
+	w.notify()
+}
+
+// SetFailureThreshold changes the number of consecutive failures that trips
+// the breaker, leaving the rest of its settings as they are, e.g. to make it
+// more tolerant while a dependency is known to be degraded.
+//
+// The failures counted so far are kept: raising the threshold past them
+// keeps the breaker closed until the new threshold is reached, while
+// lowering it to or below them trips the breaker on the next failure
+// rather than at once. Zero stops tripping on consecutive failures, as
+// for AutoTrip.Threshold, and resets the circuit if the failure rate
+// does not trip it either.
+func (w *Breaker) SetFailureThreshold(n int) {
+	w.circuit.reconfigure(func(cfg *AutoTrip) { cfg.Threshold = n })
+	w.notify()
+}
+
+// ResetFailures clears the consecutive failures and the failure rate window
+// counted toward tripping the breaker, so that it starts afresh, e.g. once an
+// incident has been fixed.
+//
+// Unlike ForceClose it does not change the state: an open breaker stays open
+// until its reset timeout passes, and ForceOpen is not ended.
+func (w *Breaker) ResetFailures() {
+	w.circuit.clear()
+}
+
+// State returns the current state of the breaker.
+//
+// A breaker disabled with Disable(true) or ForceOpen is always Open, otherwise
+// the state is that of the automatic circuit breaker.
+func (w *Breaker) State() CircuitState {
+	return w.notify()
+}
+
+// OnStateChange registers fn to be called whenever the breaker's state
+// changes, either by Disable or by the automatic circuit breaker.
+//
+// Callbacks are called synchronously, in the order they were registered,
+// by the goroutine that caused or observed the transition. No locks are
+// held while they run so they may safely call back into the Breaker, and a
+// panic in one is recovered and logged without keeping the others from running.
+func (w *Breaker) OnStateChange(fn func(old, new CircuitState)) {
+	w.smu.Lock()
+	defer w.smu.Unlock()
+	w.hooks = append(w.hooks, fn)
+}
+
+// WaitReady waits for the breaker to be closed, returning nil at once if it
+// already is, or the context's error if ctx is done first.
+//
+// The breaker closes as it is enabled or a probe succeeds, see WithAutoProbe
+// to have it probe the database without waiting for traffic.
+func (w *Breaker) WaitReady(ctx context.Context) error {
+	for {
+		w.notify()
+		w.smu.Lock()
+		if w.last == Closed {
+			w.smu.Unlock()
+			return nil
+		}
+		if w.ready == nil {
+			w.ready = make(chan struct{})
+		}
+		ready := w.ready
+		w.smu.Unlock()
+		select {
+		case <-ready:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// state computes the current state of the breaker
+func (w *Breaker) state() CircuitState {
+	if w.IsDown() {
+		return Open
+	}
+	return w.circuit.current(w.clock.Now())
+}
+
+// notify calls the state change hooks if the state has changed since
+// it was last observed, and returns the current state
+func (w *Breaker) notify() CircuitState {
+	return w.notifyBy(Audit{})
+}
+
+// notifyBy is notify for a change made by who
+func (w *Breaker) notifyBy(who Audit) CircuitState {
+	w.smu.Lock()
+	old, now := w.last, w.state()
+	w.last = now
+	hooks := w.hooks
+	if now == Closed && w.ready != nil {
+		close(w.ready)
+		w.ready = nil
+	}
+	w.smu.Unlock()
+
+	if old != now {
+		w.gen.Add(1)
+		reason := w.Reason()
+		w.logState(old, now, reason, who)
+		w.emit(Event{Type: stateEvents[now], Reason: reason, Audit: who})
+		for _, fn := range hooks {
+			w.guard("state change hook", func() { fn(old, now) })
+		}
+	}
+	return now
+}
+
+// logState logs a state change
+func (w *Breaker) logState(old, now CircuitState, reason string, who Audit) {
+	level := slog.LevelInfo
+	if now == Open {
+		level = slog.LevelWarn
+	}
+	args := []any{"from", old, "to", now}
+	if reason != "" {
+		args = append(args, "reason", reason)
+	}
+	if who.Actor != "" {
+		args = append(args, "actor", who.Actor)
+	}
+	if who.CorrelationID != "" {
+		args = append(args, "correlation_id", who.CorrelationID)
+	}
+	w.log(level, "dbreaker state changed", args...)
+}
+
+// log logs msg with args, and the breaker's name if it has one
+func (w *Breaker) log(level slog.Level, msg string, args ...any) {
+	if w.logger == nil {
+		return
+	}
+	if w.name != "" {
+		args = append([]any{"breaker", w.name}, args...)
+	}
+	w.logger.Log(context.Background(), level, msg, args...)
+}
+
+// acquire checks the circuit before an operation that reports its outcome via done,
+// an operation that is allowed counts as in flight until then
+func (w *Breaker) acquire(ctx context.Context, name string) (bool, error) {
+	if err := w.reserve(ctx); err != nil {
+		return false, err
+	}
+	if bypassed(ctx) {
+		w.enter()
+		return false, nil
+	}
+	probe, err := w.circuit.acquire(w.clock.Now())
+	w.notify()
+	if err == ErrDown {
+		w.release()
+		return probe, w.errDown(name)
+	}
+	if err == nil {
+		w.enter()
+	}
+	return probe, err
+}
+
+// errNeutral is the outcome of an operation that counts as neither a success
+// nor a failure toward tripping the breaker, see Breaker.outcome
+var errNeutral = fmt.Errorf("outcome not counted")
+
+// done records the outcome of an operation on name
+func (w *Breaker) done(name string, probe bool, err error) {
+	if err != nil && err != driver.ErrSkip && err != errNeutral && w.failing != nil && !w.counts(err) {
+		err = errNeutral
+	}
+	now := w.clock.Now()
+	if w.circuit.done(probe, err, now) {
+		// also for WithBlockNewOnly while down by hand, with no change of state
+		w.gen.Add(1)
+		last := &trip{at: now, err: err}
+		w.count(name, func(c *counters) {
+			c.trips.Add(1)
+			c.lastTrip.Store(last)
+		})
+	}
+	w.release()
+	w.leave()
+	w.notify()
+}
+
+// counts reports whether err counts toward tripping the breaker, as decided
+// by the failure classifier, or if the classifier panics
+func (w *Breaker) counts(err error) (failed bool) {
+	if w.guard("failure classifier", func() { failed = w.failing(err) }) != nil {
+		return true
+	}
+	return failed
+}
+
+// tripped reports if the circuit is open
+func (w *Breaker) tripped() bool {
+	return w.circuit.tripped(w.clock.Now())
+}
@@ -0,0 +1,679 @@
+package dbreaker
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var errMock = errors.New("mock failure")
+
+func TestAutoTrip(t *testing.T) {
+	const (
+		driver    = "wrapper-autotrip"
+		threshold = 3
+		timeout   = time.Minute
+		insert    = "insert into users values(1)"
+	)
+	clk := newFakeClock()
+	mock, native := newMock()
+	drv, err := NewDriverWithOptions(driver, native, WithClock(clk))
+	if err != nil {
+		t.Fatal(err)
+	}
+	breaker := drv.(*Breaker)
+	breaker.SetAutoTrip(AutoTrip{Threshold: threshold, ResetTimeout: timeout})
+
+	db, err := sql.Open(driver, "autotrip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(insert); err != nil {
+		t.Fatal("exec fail:", err)
+	}
+
+	// failures below the threshold are passed through
+	mock.Fail(errMock)
+	for i := 0; i < threshold; i++ {
+		if _, err := db.Exec(insert); err != errMock {
+			t.Fatalf("expected %v but got: %v", errMock, err)
+		}
+	}
+	if state := breaker.State(); state != Open {
+		t.Fatalf("expected state %v but got: %v", Open, state)
+	}
+	if _, err := db.Exec(insert); !errors.Is(err, ErrDown) {
+		t.Fatalf("expected %v but got: %v", ErrDown, err)
+	}
+
+	// a failed probe opens the circuit again
+	clk.Advance(timeout)
+	if state := breaker.State(); state != HalfOpen {
+		t.Fatalf("expected state %v but got: %v", HalfOpen, state)
+	}
+	if _, err := db.Exec(insert); err != errMock {
+		t.Fatalf("expected %v but got: %v", errMock, err)
+	}
+	if state := breaker.State(); state != Open {
+		t.Fatalf("expected state %v but got: %v", Open, state)
+	}
+
+	// a successful probe closes it
+	clk.Advance(timeout)
+	mock.Fail(nil)
+	if _, err := db.Exec(insert); err != nil {
+		t.Fatal("exec fail:", err)
+	}
+	if state := breaker.State(); state != Closed {
+		t.Fatalf("expected state %v but got: %v", Closed, state)
+	}
+}
+
+func TestAutoTripSuccessResets(t *testing.T) {
+	const (
+		driver = "wrapper-autotrip-reset"
+		insert = "insert into users values(1)"
+	)
+	mock, native := newMock()
+	drv, err := NewDriver(driver, native)
+	if err != nil {
+		t.Fatal(err)
+	}
+	breaker := drv.(*Breaker)
+	breaker.SetAutoTrip(AutoTrip{Threshold: 2})
+
+	db, err := sql.Open(driver, "autotrip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(insert); err != nil {
+		t.Fatal("exec fail:", err)
+	}
+
+	// only consecutive failures count toward tripping
+	for i := 0; i < 3; i++ {
+		mock.Fail(errMock)
+		db.Exec(insert)
+		mock.Fail(nil)
+		if _, err := db.Exec(insert); err != nil {
+			t.Fatal("exec fail:", err)
+		}
+	}
+	if state := breaker.State(); state != Closed {
+		t.Fatalf("expected state %v but got: %v", Closed, state)
+	}
+}
+
+func TestCircuitStateString(t *testing.T) {
+	tests := map[CircuitState]string{
+		Closed:           "closed",
+		Open:             "open",
+		HalfOpen:         "half-open",
+		CircuitState(42): "CircuitState(42)",
+	}
+	for state, expect := range tests {
+		if got := state.String(); got != expect {
+			t.Errorf("expected %q but got: %q", expect, got)
+		}
+	}
+}
+
+func TestStateManual(t *testing.T) {
+	mock, native := newMock()
+	drv, err := NewDriver("wrapper-state", native)
+	if err != nil {
+		t.Fatal(err)
+	}
+	breaker := drv.(*Breaker)
+	breaker.SetAutoTrip(AutoTrip{Threshold: 1, ResetTimeout: time.Hour})
+
+	if state := breaker.State(); state != Closed {
+		t.Fatalf("expected state %v but got: %v", Closed, state)
+	}
+	breaker.Disable(true)
+	if state := breaker.State(); state != Open {
+		t.Fatalf("expected state %v but got: %v", Open, state)
+	}
+	breaker.Disable(false)
+	if state := breaker.State(); state != Closed {
+		t.Fatalf("expected state %v but got: %v", Closed, state)
+	}
+
+	// re-enabling does not close a circuit that tripped on its own
+	db, err := sql.Open("wrapper-state", "state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	mock.Fail(errMock)
+	db.Exec("insert into users values(1)")
+	if state := breaker.State(); state != Open {
+		t.Fatalf("expected state %v but got: %v", Open, state)
+	}
+	breaker.Disable(true)
+	breaker.Disable(false)
+	if state := breaker.State(); state != Open {
+		t.Fatalf("expected state %v but got: %v", Open, state)
+	}
+}
+
+func TestOnStateChange(t *testing.T) {
+	type change struct {
+		old, new CircuitState
+	}
+	mock, native := newMock()
+	drv, err := NewDriver("wrapper-hooks", native)
+	if err != nil {
+		t.Fatal(err)
+	}
+	breaker := drv.(*Breaker)
+	breaker.SetAutoTrip(AutoTrip{Threshold: 1, ResetTimeout: time.Hour})
+
+	var first, second []change
+	breaker.OnStateChange(func(old, new CircuitState) {
+		first = append(first, change{old, new})
+		// re-entering the breaker from a hook must not deadlock
+		breaker.IsDown()
+		breaker.State()
+	})
+	breaker.OnStateChange(func(old, new CircuitState) {
+		if len(second) == len(first) {
+			t.Error("hooks called out of registration order")
+		}
+		second = append(second, change{old, new})
+	})
+
+	breaker.Disable(true)
+	breaker.Disable(false)
+
+	db, err := sql.Open("wrapper-hooks", "hooks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	mock.Fail(errMock)
+	db.Exec("insert into users values(1)")
+
+	expect := []change{{Closed, Open}, {Open, Closed}, {Closed, Open}}
+	if len(first) != len(expect) {
+		t.Fatalf("expected changes %v but got: %v", expect, first)
+	}
+	for i := range expect {
+		if first[i] != expect[i] || second[i] != expect[i] {
+			t.Fatalf("expected changes %v but got: %v and %v", expect, first, second)
+		}
+	}
+}
+
+func TestHalfOpenMaxProbes(t *testing.T) {
+	const probes = 3
+	clk := newFakeClock()
+	_, native := newMock()
+	breaker := newBreaker(native,
+		WithClock(clk),
+		WithFailureThreshold(1),
+		WithResetTimeout(time.Minute),
+		WithHalfOpenMaxProbes(probes),
+	)
+
+	// trip lets the maximum number of probes through once half-open
+	trip := func() {
+		t.Helper()
+		breaker.circuit.mu.Lock()
+		breaker.circuit.trip(clk.Now())
+		breaker.circuit.mu.Unlock()
+		clk.Advance(time.Minute)
+		for i := 0; i < probes; i++ {
+			if probe, err := breaker.acquire(context.Background(), ""); err != nil || !probe {
+				t.Fatalf("expected probe %d to be let through but got: %v %v", i, probe, err)
+			}
+		}
+		if _, err := breaker.acquire(context.Background(), ""); !errors.Is(err, ErrDown) {
+			t.Fatalf("expected probes over the limit to get %v but got: %v", ErrDown, err)
+		}
+	}
+
+	// a successful probe closes the circuit
+	trip()
+	breaker.done("", true, nil)
+	if state := breaker.State(); state != Closed {
+		t.Fatalf("expected state %v but got: %v", Closed, state)
+	}
+	breaker.done("", true, nil)
+	breaker.done("", true, nil)
+
+	// a failed probe opens it again and restarts the reset timer
+	trip()
+	clk.Advance(time.Second)
+	breaker.done("", true, errMock)
+	if state := breaker.State(); state != Open {
+		t.Fatalf("expected state %v but got: %v", Open, state)
+	}
+	breaker.done("", true, nil)
+	breaker.done("", true, nil)
+	clk.Advance(time.Minute - time.Second)
+	if state := breaker.State(); state != Open {
+		t.Fatalf("expected state %v until the timer restarts but got: %v", Open, state)
+	}
+	clk.Advance(time.Second)
+	if state := breaker.State(); state != HalfOpen {
+		t.Fatalf("expected state %v but got: %v", HalfOpen, state)
+	}
+}
+
+func TestHalfOpenSuccessThreshold(t *testing.T) {
+	const successes = 3
+	clk := newFakeClock()
+	_, native := newMock()
+	breaker := newBreaker(native,
+		WithClock(clk),
+		WithFailureThreshold(1),
+		WithResetTimeout(time.Minute),
+		WithHalfOpenSuccessThreshold(successes),
+	)
+
+	// probe runs a single half-open probe with the given outcome
+	probe := func(err error) {
+		t.Helper()
+		probe, perr := breaker.acquire(context.Background(), "")
+		if perr != nil || !probe {
+			t.Fatalf("expected a probe to be let through but got: %v %v", probe, perr)
+		}
+		breaker.done("", probe, err)
+	}
+
+	breaker.done("", false, errMock)
+	clk.Advance(time.Minute)
+	for i := 1; i < successes; i++ {
+		probe(nil)
+		if state := breaker.State(); state != HalfOpen {
+			t.Fatalf("expected state %v after %d successes but got: %v", HalfOpen, i, state)
+		}
+	}
+
+	// a failure reopens it and starts the count over
+	probe(errMock)
+	if state := breaker.State(); state != Open {
+		t.Fatalf("expected state %v but got: %v", Open, state)
+	}
+	clk.Advance(time.Minute)
+	for i := 1; i < successes; i++ {
+		probe(nil)
+	}
+	if state := breaker.State(); state != HalfOpen {
+		t.Fatalf("expected state %v but got: %v", HalfOpen, state)
+	}
+	probe(nil)
+	if state := breaker.State(); state != Closed {
+		t.Fatalf("expected state %v after %d successes but got: %v", Closed, successes, state)
+	}
+}
+
+func TestDisableIdempotent(t *testing.T) {
+	_, native := newMock()
+	breaker := newBreaker(native)
+	var changes []CircuitState
+	var mu sync.Mutex
+	breaker.OnStateChange(func(old, new CircuitState) {
+		mu.Lock()
+		defer mu.Unlock()
+		changes = append(changes, new)
+	})
+	events := breaker.Events()
+
+	breaker.Disable(true)
+	breaker.Disable(true)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			breaker.Disable(true)
+		}()
+	}
+	wg.Wait()
+	breaker.CloseEvents()
+
+	if len(changes) != 1 || changes[0] != Open {
+		t.Fatalf("expected the hook to fire once for %v but got: %v", Open, changes)
+	}
+	var got []Event
+	for e := range events {
+		got = append(got, e)
+	}
+	if len(got) != 1 || got[0].Type != EventTrip {
+		t.Fatalf("expected a single trip event but got: %+v", got)
+	}
+}
+
+func TestWaitReady(t *testing.T) {
+	ctx := context.Background()
+	_, native := newMock()
+	breaker := newBreaker(native)
+	if err := breaker.WaitReady(ctx); err != nil {
+		t.Fatalf("expected a closed breaker to be ready but got: %v", err)
+	}
+
+	breaker.Disable(true)
+	ready := make(chan error, 1)
+	go func() { ready <- breaker.WaitReady(ctx) }()
+	select {
+	case err := <-ready:
+		t.Fatalf("expected to wait while disabled but got: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	breaker.Disable(false)
+	select {
+	case err := <-ready:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected to be ready once enabled")
+	}
+
+	// waiting gives up with the context
+	breaker.Disable(true)
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := breaker.WaitReady(cancelled); err != context.Canceled {
+		t.Fatalf("expected %v but got: %v", context.Canceled, err)
+	}
+}
+
+func TestResetFailures(t *testing.T) {
+	const (
+		wrapper = "wrapper-reset-failures"
+		insert  = "insert into users values(1)"
+	)
+	clk := newFakeClock()
+	mock, native := newMock()
+	drv, err := NewDriverWithOptions(wrapper, native,
+		WithClock(clk),
+		WithFailureThreshold(3),
+		WithFailureRate(0.5, time.Minute, 10),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	breaker := drv.(*Breaker)
+	db, err := sql.Open(wrapper, "reset-failures")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	mock.Fail(errMock)
+	for i := 0; i < 2; i++ {
+		if _, err := db.Exec(insert); err != errMock {
+			t.Fatalf("expected %v but got: %v", errMock, err)
+		}
+	}
+	breaker.ResetFailures()
+	breaker.circuit.mu.Lock()
+	failures, outcomes := breaker.circuit.failures, breaker.circuit.outcomes
+	breaker.circuit.mu.Unlock()
+	if failures != 0 || outcomes != (ring{}) {
+		t.Fatalf("expected no failures after reset but got: %d, %+v", failures, outcomes)
+	}
+
+	// it takes the full threshold to trip again
+	for i := 0; i < 2; i++ {
+		db.Exec(insert)
+	}
+	if state := breaker.State(); state != Closed {
+		t.Fatalf("expected state %v but got: %v", Closed, state)
+	}
+	db.Exec(insert)
+	if state := breaker.State(); state != Open {
+		t.Fatalf("expected state %v but got: %v", Open, state)
+	}
+
+	// and resetting leaves an open breaker open
+	breaker.ResetFailures()
+	if state := breaker.State(); state != Open {
+		t.Fatalf("expected state %v after reset but got: %v", Open, state)
+	}
+}
+
+func TestBackoffReset(t *testing.T) {
+	const (
+		wrapper = "wrapper-backoff-reset"
+		insert  = "insert into users values(1)"
+	)
+	clk := newFakeClock()
+	mock, native := newMock()
+	drv, err := NewDriverWithOptions(wrapper, native,
+		WithClock(clk),
+		WithFailureThreshold(1),
+		WithResetTimeout(time.Minute),
+		WithBackoffReset(4*time.Minute),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	breaker := drv.(*Breaker)
+	db, err := sql.Open(wrapper, "backoff-reset")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.Ping(); err != nil {
+		t.Fatal(err)
+	}
+
+	// expect checks the breaker stays open for timeout, then half-opens
+	expect := func(timeout time.Duration) {
+		t.Helper()
+		clk.Advance(timeout - time.Second)
+		if state := breaker.State(); state != Open {
+			t.Fatalf("expected state %v before %v but got: %v", Open, timeout, state)
+		}
+		clk.Advance(time.Second)
+		if state := breaker.State(); state != HalfOpen {
+			t.Fatalf("expected state %v after %v but got: %v", HalfOpen, timeout, state)
+		}
+	}
+	fail := func() {
+		t.Helper()
+		mock.Fail(errMock)
+		if _, err := db.Exec(insert); err != errMock {
+			t.Fatalf("expected %v but got: %v", errMock, err)
+		}
+	}
+	heal := func() {
+		t.Helper()
+		mock.Fail(nil)
+		if _, err := db.Exec(insert); err != nil {
+			t.Fatal(err)
+		}
+		if state := breaker.State(); state != Closed {
+			t.Fatalf("expected state %v but got: %v", Closed, state)
+		}
+	}
+
+	fail()
+	expect(time.Minute)
+
+	// a failed probe doubles the timeout
+	fail()
+	expect(2 * time.Minute)
+
+	// as does tripping again soon after recovering, up to the max
+	heal()
+	fail()
+	expect(4 * time.Minute)
+	heal()
+	fail()
+	expect(4 * time.Minute)
+
+	// staying closed for the max starts over
+	heal()
+	clk.Advance(4 * time.Minute)
+	fail()
+	expect(time.Minute)
+}
+
+func TestHookPanic(t *testing.T) {
+	const wrapper = "wrapper-hook-panic"
+	rec := &recorder{}
+	mock, native := newMock()
+	drv, err := NewDriverWithOptions(wrapper, native,
+		WithLogger(slog.New(rec)),
+		WithFailureThreshold(1),
+		WithFailureClassifier(func(err error) bool { panic("classifier") }),
+		WithQueryInterceptor(func(ctx context.Context, query string) error {
+			if query == "delete from users" {
+				panic("interceptor")
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	breaker := drv.(*Breaker)
+	var changes []CircuitState
+	breaker.OnStateChange(func(old, new CircuitState) { panic("hook") })
+	breaker.OnStateChange(func(old, new CircuitState) { changes = append(changes, new) })
+
+	// a panicking hook doesn't keep the breaker from changing state
+	breaker.Disable(true)
+	breaker.Disable(false)
+	if len(changes) != 2 {
+		t.Fatalf("expected the next hook to see 2 changes but got: %v", changes)
+	}
+	if lines := rec.lines(); len(lines) == 0 || !strings.Contains(strings.Join(lines, "\n"), "ERROR dbreaker recovered from panic breaker=wrapper-hook-panic hook=state change hook panic=hook") {
+		t.Fatalf("expected the panic to be logged but got: %q", lines)
+	}
+
+	db, err := sql.Open(wrapper, "hook-panic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec("insert into users values(1)"); err != nil {
+		t.Fatalf("expected the breaker to work but got: %v", err)
+	}
+
+	// nor does a panicking interceptor, which refuses the statement
+	if _, err := db.Exec("delete from users"); err == nil || err.Error() != "query interceptor panicked: interceptor" {
+		t.Fatalf("expected the panic as an error but got: %v", err)
+	}
+
+	// and a panicking classifier counts the failure
+	mock.Fail(errMock)
+	if _, err := db.Exec("insert into users values(1)"); err != errMock {
+		t.Fatalf("expected %v but got: %v", errMock, err)
+	}
+	if state := breaker.State(); state != Open {
+		t.Fatalf("expected state %v but got: %v", Open, state)
+	}
+}
+
+func TestWarmup(t *testing.T) {
+	const (
+		wrapper = "wrapper-warmup"
+		insert  = "insert into users values(1)"
+	)
+	clk := newFakeClock()
+	mock, native := newMock()
+	drv, err := NewDriverWithOptions(wrapper, native,
+		WithClock(clk),
+		WithFailureThreshold(2),
+		WithWarmup(time.Minute),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	breaker := drv.(*Breaker)
+	db, err := sql.Open(wrapper, "warmup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	mock.Fail(errMock)
+
+	// warm checks failures during the warmup don't trip, but the first after does
+	warm := func() {
+		t.Helper()
+		for i := 0; i < 3; i++ {
+			if _, err := db.Exec(insert); err != errMock {
+				t.Fatalf("expected %v but got: %v", errMock, err)
+			}
+		}
+		if state := breaker.State(); state != Closed {
+			t.Fatalf("expected state %v during the warmup but got: %v", Closed, state)
+		}
+		clk.Advance(time.Minute)
+		if _, err := db.Exec(insert); err != errMock {
+			t.Fatalf("expected %v but got: %v", errMock, err)
+		}
+		if state := breaker.State(); state != Open {
+			t.Fatalf("expected state %v after the warmup but got: %v", Open, state)
+		}
+	}
+	warm()
+	breaker.ForceClose()
+	warm()
+}
+
+func TestSetFailureThreshold(t *testing.T) {
+	const (
+		wrapper = "wrapper-set-threshold"
+		insert  = "insert into users values(1)"
+	)
+	mock, native := newMock()
+	drv, err := NewDriverWithOptions(wrapper, native, WithFailureThreshold(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	breaker := drv.(*Breaker)
+	db, err := sql.Open(wrapper, "set-threshold")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	mock.Fail(errMock)
+	fail := func(n int) {
+		t.Helper()
+		for i := 0; i < n; i++ {
+			if _, err := db.Exec(insert); err != errMock {
+				t.Fatalf("expected %v but got: %v", errMock, err)
+			}
+		}
+	}
+
+	fail(2)
+	breaker.SetFailureThreshold(5)
+	fail(2)
+	if state := breaker.State(); state != Closed {
+		t.Fatalf("expected state %v past the old threshold but got: %v", Closed, state)
+	}
+	if cfg := breaker.circuit.config(); cfg.Threshold != 5 {
+		t.Fatalf("expected threshold 5 but got: %d", cfg.Threshold)
+	}
+
+	// lowering it below the failures so far trips on the next failure
+	breaker.SetFailureThreshold(3)
+	if state := breaker.State(); state != Closed {
+		t.Fatalf("expected state %v until the next failure but got: %v", Closed, state)
+	}
+	fail(1)
+	if state := breaker.State(); state != Open {
+		t.Fatalf("expected state %v but got: %v", Open, state)
+	}
+}
@@ -0,0 +1,272 @@
+package dbreaker
+
+import (
+	"fmt"
+	"strings"
+)
+
+// StatementCategory is a kind of statement, as classified by its leading keyword.
+//
+// Queries are not parsed: each of the statements of a query, split on the
+// semicolons outside of quotes and comments, is classified by its leading
+// keyword, or the one following the common table expressions of a WITH
+// clause, and a query of several statements is in the categories of all of
+// them. Side effects of functions or procedures are not detected, e.g.
+// "SELECT nextval('seq')" is a Select and "CALL purge()" is Unclassified,
+// nor are those of "SELECT ... INTO" and "SELECT ... FOR UPDATE".
+type StatementCategory int
+
+const (
+	// Unclassified statements are in none of the other categories, e.g. PRAGMA or SET
+	Unclassified StatementCategory = iota
+	// DDL statements change the schema or permissions: ALTER, CREATE, DROP,
+	// GRANT, RENAME, REVOKE and TRUNCATE
+	DDL
+	// DML statements change data: DELETE, INSERT, MERGE, REPLACE, UPDATE and UPSERT
+	DML
+	// Select statements read data: SELECT and VALUES
+	Select
+	// Transaction statements control transactions: BEGIN, COMMIT, END, RELEASE,
+	// ROLLBACK, SAVEPOINT and START, as do Begin and BeginTx on a connection
+	Transaction
+)
+
+func (c StatementCategory) String() string {
+	switch c {
+	case Unclassified:
+		return "unclassified"
+	case DDL:
+		return "DDL"
+	case DML:
+		return "DML"
+	case Select:
+		return "select"
+	case Transaction:
+		return "transaction"
+	}
+	return fmt.Sprintf("StatementCategory(%d)", int(c))
+}
+
+// categories maps leading keywords to the category of their statements
+var categories = map[string]StatementCategory{
+	"ALTER":     DDL,
+	"CREATE":    DDL,
+	"DROP":      DDL,
+	"GRANT":     DDL,
+	"RENAME":    DDL,
+	"REVOKE":    DDL,
+	"TRUNCATE":  DDL,
+	"DELETE":    DML,
+	"INSERT":    DML,
+	"MERGE":     DML,
+	"REPLACE":   DML,
+	"UPDATE":    DML,
+	"UPSERT":    DML,
+	"SELECT":    Select,
+	"VALUES":    Select,
+	"BEGIN":     Transaction,
+	"COMMIT":    Transaction,
+	"END":       Transaction,
+	"RELEASE":   Transaction,
+	"ROLLBACK":  Transaction,
+	"SAVEPOINT": Transaction,
+	"START":     Transaction,
+}
+
+// category returns the category of the leading statement of query
+func category(query string) StatementCategory {
+	return categories[keyword(query)]
+}
+
+// kinds returns the categories of all the statements of query as a bit
+// set, with the statements split in each of the ways described for isWrite
+func kinds(query string) uint32 {
+	bits := uint32(1) << category(query)
+	for _, d := range dialects {
+		for _, stmt := range d.statements(query) {
+			bits |= 1 << category(stmt)
+		}
+	}
+	return bits
+}
+
+// writes are the leading keywords of statements that modify the database
+var writes = map[string]bool{
+	"ALTER":    true,
+	"CREATE":   true,
+	"DELETE":   true,
+	"DROP":     true,
+	"GRANT":    true,
+	"INSERT":   true,
+	"MERGE":    true,
+	"RENAME":   true,
+	"REPLACE":  true,
+	"REVOKE":   true,
+	"TRUNCATE": true,
+	"UPDATE":   true,
+	"UPSERT":   true,
+}
+
+// verbs are the keywords that can follow the CTEs of a WITH clause
+var verbs = map[string]bool{
+	"DELETE":  true,
+	"INSERT":  true,
+	"MERGE":   true,
+	"SELECT":  true,
+	"UPDATE":  true,
+	"VALUES":  true,
+	"REPLACE": true,
+}
+
+// isWrite reports whether query looks like it modifies the database.
+//
+// This is a heuristic based on the leading keyword of each statement,
+// it does not parse SQL. A query of several statements separated by
+// semicolons is a write if any of them is, with the statements split in
+// each of the ways SQL dialects quote and comment, so that one can't hide
+// from the others behind a quote or comment of another dialect.
+// Known limits:
+//   - functions or procedures with side effects are not detected,
+//     e.g. "SELECT nextval('seq')" or "CALL purge()" are treated as reads
+//   - "SELECT ... INTO" and "SELECT ... FOR UPDATE" are treated as reads
+//   - unrecognized statements (PRAGMA, SET, EXPLAIN, ...) are treated as reads
+//   - nested comments and dollar quoting are not recognized, so their
+//     contents may be taken for statements of their own
+func isWrite(query string) bool {
+	for _, d := range dialects {
+		for _, stmt := range d.statements(query) {
+			if writes[keyword(stmt)] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// keyword returns the leading keyword of query in upper case,
+// skipping whitespace and comments.
+//
+// For statements starting with a WITH clause the keyword of the
+// statement following the common table expressions is returned.
+func keyword(query string) string {
+	tok, rest := lenient.token(query)
+	verb := strings.ToUpper(tok)
+	if verb != "WITH" {
+		return verb
+	}
+	depth := 0
+	for tok, rest = lenient.token(rest); tok != ""; tok, rest = lenient.token(rest) {
+		switch tok {
+		case "(":
+			depth++
+		case ")":
+			depth--
+		default:
+			if depth == 0 && verbs[strings.ToUpper(tok)] {
+				return strings.ToUpper(tok)
+			}
+		}
+	}
+	return verb
+}
+
+// dialect is a way of quoting and commenting SQL, beyond the standard
+// quotes with doubled quotes as escapes and -- and /* */ comments
+type dialect struct {
+	escapes bool // a backslash escapes the next character in quotes, as in MySQL
+	hash    bool // # comments to the end of the line, as in MySQL
+}
+
+// lenient is the dialect leading keywords are found in
+var lenient = dialect{hash: true}
+
+// dialects are the dialects queries are split into statements in
+var dialects = []dialect{{}, {escapes: true}, {hash: true}, {escapes: true, hash: true}}
+
+// statements splits query into its statements on the semicolons
+// outside of quotes and comments, leaving out empty statements
+func (d dialect) statements(query string) []string {
+	var stmts []string
+	start := 0
+	add := func(end int) {
+		if stmt := query[start:end]; d.skip(stmt) != "" {
+			stmts = append(stmts, stmt)
+		}
+	}
+	for tok, rest := d.token(query); tok != ""; tok, rest = d.token(rest) {
+		if tok == ";" {
+			end := len(query) - len(rest)
+			add(end - 1)
+			start = end
+		}
+	}
+	add(len(query))
+	return stmts
+}
+
+// token returns the next token in s along with the remainder of s.
+//
+// A token is a word, a quoted literal or identifier, or a single
+// punctuation character. Whitespace and comments are skipped and
+// an empty token is returned at the end of the input.
+func (d dialect) token(s string) (string, string) {
+	s = d.skip(s)
+	if s == "" {
+		return "", ""
+	}
+	switch c := s[0]; {
+	case isWord(c):
+		i := 1
+		for i < len(s) && isWord(s[i]) {
+			i++
+		}
+		return s[:i], s[i:]
+	case c == '\'' || c == '"' || c == '`':
+		i := 1
+		for i < len(s) {
+			if d.escapes && s[i] == '\\' {
+				i += 2
+				continue
+			}
+			if s[i] == c {
+				// a doubled quote is an escaped quote
+				if i+1 < len(s) && s[i+1] == c {
+					i += 2
+					continue
+				}
+				return s[:i+1], s[i+1:]
+			}
+			i++
+		}
+		return s, ""
+	default:
+		return s[:1], s[1:]
+	}
+}
+
+// skip strips leading whitespace and comments from s
+func (d dialect) skip(s string) string {
+	for {
+		s = strings.TrimLeft(s, " \t\r\n\f")
+		switch {
+		case strings.HasPrefix(s, "--"), d.hash && strings.HasPrefix(s, "#"):
+			i := strings.IndexByte(s, '\n')
+			if i < 0 {
+				return ""
+			}
+			s = s[i+1:]
+		case strings.HasPrefix(s, "/*"):
+			i := strings.Index(s[2:], "*/")
+			if i < 0 {
+				return ""
+			}
+			s = s[i+4:]
+		default:
+			return s
+		}
+	}
+}
+
+func isWord(c byte) bool {
+	return c == '_' || c == '$' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
@@ -0,0 +1,103 @@
+package dbreaker
+
+import (
+	"testing"
+)
+
+func TestIsWrite(t *testing.T) {
+	tests := []struct {
+		query string
+		write bool
+	}{
+		{"select * from users", false},
+		{"  SELECT 1", false},
+		{"-- leading comment\nselect 1", false},
+		{"/* block\ncomment */ select 1", false},
+		{"with t as (select 1) select * from t", false},
+		{"WITH RECURSIVE t(n) AS (SELECT 1 UNION ALL SELECT n+1 FROM t) SELECT n FROM t", false},
+		{"with t as (select 1), u as (select 2) select * from t, u", false},
+		{"with t as (select id from users) delete from users where id in (select id from t)", true},
+		{"insert into users values (1)", true},
+		{"\n\tUpdate users set x = 1", true},
+		{"delete from users", true},
+		{"create table t (id int)", true},
+		{"drop table t", true},
+		{"alter table t add column x int", true},
+		{"truncate table t", true},
+		{"/* insert */ select 'insert'", false},
+		{"-- select\ninsert into t values (1)", true},
+		{"pragma table_info(users)", false},
+		{"", false},
+		{"   ", false},
+		{"select 1; select 2;", false},
+		{"select 1; delete from users", true},
+		{"SELECT 1;\n/* cleanup */ DROP TABLE users;", true},
+		{"select ';delete from users'", false},
+		{"select 'it''s; delete from users'", false},
+		{`select "a;b"; select 2`, false},
+		{"select 1 -- ; delete from users", false},
+		{"select 1 /* ; delete from users */; select 2", false},
+		{"select 1; -- comment\n update users set x = 1", true},
+		{`select 'C:\'; delete from users; -- '`, true},
+		{`select 'a\''; delete from users; -- '`, true},
+		{"select 1 # it's\n; delete from users; -- '", true},
+		{";;select 1;;", false},
+	}
+	for _, tt := range tests {
+		if got := isWrite(tt.query); got != tt.write {
+			t.Errorf("isWrite(%q) = %v, expected %v", tt.query, got, tt.write)
+		}
+	}
+}
+
+func TestCategory(t *testing.T) {
+	tests := map[string]StatementCategory{
+		"create table t (id int)":                    DDL,
+		"ALTER TABLE t ADD COLUMN x int":             DDL,
+		"drop index i":                               DDL,
+		"insert into t values (1)":                   DML,
+		"with t as (select 1) update u set x = 1":    DML,
+		"select * from t":                            Select,
+		"with t as (select 1) select * from t":       Select,
+		"begin transaction":                          Transaction,
+		"COMMIT":                                     Transaction,
+		"pragma table_info(users)":                   Unclassified,
+		"":                                           Unclassified,
+		"/* create */ insert into t values ('drop')": DML,
+	}
+	for query, expect := range tests {
+		if got := category(query); got != expect {
+			t.Errorf("category(%q): expected %v but got: %v", query, expect, got)
+		}
+	}
+}
+
+func TestStatements(t *testing.T) {
+	tests := []struct {
+		query string
+		d     dialect
+		stmts []string
+	}{
+		{"select 1", dialect{}, []string{"select 1"}},
+		{"select 1; select 2;", dialect{}, []string{"select 1", " select 2"}},
+		{" ; ;-- none\n", dialect{}, nil},
+		{"select 'a;b'; select 2", dialect{}, []string{"select 'a;b'", " select 2"}},
+		{`select 'a\'; b'`, dialect{}, []string{`select 'a\'`, ` b'`}},
+		{`select 'a\'; b'`, dialect{escapes: true}, []string{`select 'a\'; b'`}},
+		{"select 1 # ;\n", dialect{}, []string{"select 1 # "}},
+		{"select 1 # ;\n", dialect{hash: true}, []string{"select 1 # ;\n"}},
+	}
+	for _, tt := range tests {
+		got := tt.d.statements(tt.query)
+		if len(got) != len(tt.stmts) {
+			t.Errorf("%+v.statements(%q) = %q, expected %q", tt.d, tt.query, got, tt.stmts)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.stmts[i] {
+				t.Errorf("%+v.statements(%q) = %q, expected %q", tt.d, tt.query, got, tt.stmts)
+				break
+			}
+		}
+	}
+}
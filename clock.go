@@ -0,0 +1,23 @@
+package dbreaker
+
+import (
+	"time"
+)
+
+// Clock is the source of time for the breaker's timer driven behavior,
+// such as reset timeouts and maintenance windows
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// realClock is a Clock backed by the time package
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
@@ -0,0 +1,124 @@
+package dbreaker
+
+import (
+	"database/sql"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a manually advanced clock for time based tests
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []waiter
+}
+
+type waiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.waiters = append(c.waiters, waiter{at: c.now.Add(d), ch: ch})
+	return ch
+}
+
+// Advance moves the clock forward, firing any timers that come due
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	pending := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.at.After(c.now) {
+			pending = append(pending, w)
+		} else {
+			w.ch <- c.now
+		}
+	}
+	c.waiters = pending
+}
+
+// BlockUntil waits for n timers to be pending on the clock
+func (c *fakeClock) BlockUntil(n int) {
+	for {
+		c.mu.Lock()
+		pending := len(c.waiters)
+		c.mu.Unlock()
+		if pending >= n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestClockResetTimeout(t *testing.T) {
+	const (
+		driver  = "wrapper-clock"
+		timeout = time.Minute
+	)
+	clk := newFakeClock()
+	mock, native := newMock()
+	drv, err := NewDriverWithOptions(driver, native,
+		WithClock(clk),
+		WithFailureThreshold(1),
+		WithResetTimeout(timeout),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	breaker := drv.(*Breaker)
+	db, err := sql.Open(driver, "clock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	mock.Fail(errMock)
+	db.Exec("insert into users values(1)")
+	if state := breaker.State(); state != Open {
+		t.Fatalf("expected state %v but got: %v", Open, state)
+	}
+
+	clk.Advance(timeout - time.Second)
+	if state := breaker.State(); state != Open {
+		t.Fatalf("expected state %v at %v but got: %v", Open, clk.Now(), state)
+	}
+	clk.Advance(time.Second)
+	if state := breaker.State(); state != HalfOpen {
+		t.Fatalf("expected state %v at %v but got: %v", HalfOpen, clk.Now(), state)
+	}
+
+	// a failed probe restarts the timeout from the time of the failure
+	db.Exec("insert into users values(1)")
+	clk.Advance(timeout - time.Second)
+	if state := breaker.State(); state != Open {
+		t.Fatalf("expected state %v at %v but got: %v", Open, clk.Now(), state)
+	}
+	clk.Advance(time.Second)
+	mock.Fail(nil)
+	if _, err := db.Exec("insert into users values(1)"); err != nil {
+		t.Fatal("exec fail:", err)
+	}
+	if state := breaker.State(); state != Closed {
+		t.Fatalf("expected state %v but got: %v", Closed, state)
+	}
+}
@@ -0,0 +1,143 @@
+package dbreaker
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+)
+
+// BreakerConnector is a driver.Connector for a single data source name
+// with a Breaker of its own, for use with sql.OpenDB.
+//
+// Unlike NewDriver it does not register a driver name, so any number
+// of them can be created, each with independent state: its own circuit,
+// Stats, hooks and names disabled by DisableName, even with the same
+// native driver and data source name as others.
+type BreakerConnector struct {
+	*Breaker
+	c driver.Connector
+}
+
+// NewConnector returns a connector to dsn using the native driver
+func NewConnector(native, dsn string, opts ...Option) (*BreakerConnector, error) {
+	// nothing is started until the connector is known to be usable
+	w := configure(native, opts...)
+	c, err := w.OpenConnector(dsn)
+	if err != nil {
+		w.Close()
+		return nil, err
+	}
+	w.start()
+	return &BreakerConnector{Breaker: w, c: c}, nil
+}
+
+// WrapDB opens a database for dsn using the native driver, gated by a
+// Breaker of its own which is returned alongside it.
+//
+// It is shorthand for NewConnector followed by sql.OpenDB, except that
+// the Breaker's CloseIdleConnections applies to the database, as do the
+// pool options WithMaxOpenConns, WithMaxIdleConns and WithConnMaxLifetime.
+// Closing the database also closes the Breaker.
+func WrapDB(native, dsn string, opts ...Option) (*sql.DB, Downer, error) {
+	bc, err := NewConnector(native, dsn, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	db := sql.OpenDB(bc)
+	bc.pool.apply(db)
+	bc.mu.Lock()
+	bc.dbs = append(bc.dbs, db)
+	bc.mu.Unlock()
+	return db, bc.Breaker, nil
+}
+
+// Connect satisfies the driver.Connector interface
+func (bc *BreakerConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return bc.c.Connect(ctx)
+}
+
+// Driver satisfies the driver.Connector interface
+func (bc *BreakerConnector) Driver() driver.Driver {
+	return bc.Breaker
+}
+
+// OpenConnector satisfies the driver.DriverContext interface.
+//
+// The inner driver's connector is created once per data source name,
+// so drivers that implement DriverContext only parse the name once.
+func (w *Breaker) OpenConnector(name string) (driver.Connector, error) {
+	name, err := w.rewriteDSN(name)
+	if err != nil {
+		return nil, err
+	}
+	w.dsn.Store(&name)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	drv, err := w.inner()
+	if err != nil {
+		return nil, err
+	}
+	inner, ok := w.conns[name]
+	if !ok {
+		if dc, ok := drv.(driver.DriverContext); ok {
+			if inner, err = dc.OpenConnector(name); err != nil {
+				return nil, err
+			}
+		} else {
+			inner = dsnConnector{name: name, drv: drv}
+		}
+		w.conns[name] = inner
+	}
+	return &connector{w: w, name: name, inner: inner}, nil
+}
+
+// rewriteDSN returns name as changed by WithDSNRewriter
+func (w *Breaker) rewriteDSN(name string) (dsn string, err error) {
+	if w.rewrite == nil {
+		return name, nil
+	}
+	if perr := w.guard("DSN rewriter", func() { dsn, err = w.rewrite(name) }); perr != nil {
+		return "", perr
+	}
+	return dsn, err
+}
+
+// connector gates connections made by the inner driver's connector
+type connector struct {
+	w     *Breaker
+	name  string
+	inner driver.Connector
+}
+
+// Connect satisfies the driver.Connector interface, passing ctx on to the
+// inner connector. It returns the error of ctx without connecting if ctx
+// is already done.
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	return c.w.connect(ctx, c.name, func() (driver.Conn, error) {
+		return c.inner.Connect(ctx)
+	})
+}
+
+// Driver returns the Breaker that created the connector
+func (c *connector) Driver() driver.Driver {
+	return c.w
+}
+
+// dsnConnector is a connector for drivers that do not implement DriverContext
+type dsnConnector struct {
+	name string
+	drv  driver.Driver
+}
+
+// Connect opens a connection with the driver, which can't be given ctx,
+// unless ctx is already done
+func (c dsnConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.drv.Open(c.name)
+}
+
+func (c dsnConnector) Driver() driver.Driver {
+	return c.drv
+}
@@ -0,0 +1,273 @@
+package dbreaker
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"expvar"
+	"path/filepath"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestOpenConnector(t *testing.T) {
+	const driver = "wrapper-connector"
+	ctx := context.Background()
+	drv, err := NewDriver(driver, "sqlite3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	breaker := drv.(*Breaker)
+	connector, err := breaker.OpenConnector(filepath.Join(t.TempDir(), "connector.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if connector.Driver() != drv {
+		t.Fatal("expected the connector's driver to be the breaker")
+	}
+
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	if _, err := db.ExecContext(ctx, "create table users (id integer primary key)"); err != nil {
+		t.Fatal("exec fail:", err)
+	}
+
+	breaker.Disable(true)
+	if _, err := connector.Connect(ctx); !errors.Is(err, ErrDown) {
+		t.Fatalf("expected %v but got: %v", ErrDown, err)
+	}
+	if _, err := db.ExecContext(ctx, "insert into users values(1)"); !errors.Is(err, ErrDown) {
+		t.Fatalf("expected %v but got: %v", ErrDown, err)
+	}
+	breaker.Disable(false)
+	if _, err := db.ExecContext(ctx, "insert into users values(1)"); err != nil {
+		t.Fatal("exec fail:", err)
+	}
+}
+
+func TestWrapDB(t *testing.T) {
+	ctx := context.Background()
+	db, breaker, err := WrapDB("sqlite3", filepath.Join(t.TempDir(), "wrap.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.ExecContext(ctx, "create table users (id integer primary key)"); err != nil {
+		t.Fatal("exec fail:", err)
+	}
+
+	breaker.Disable(true)
+	if _, err := db.ExecContext(ctx, "insert into users values(1)"); !errors.Is(err, ErrDown) {
+		t.Fatalf("expected %v but got: %v", ErrDown, err)
+	}
+	breaker.Disable(false)
+	if _, err := db.ExecContext(ctx, "insert into users values(1)"); err != nil {
+		t.Fatal("exec fail:", err)
+	}
+
+	// closing the database closes the breaker
+	db.Close()
+	if !breaker.(*Breaker).isClosed() {
+		t.Fatal("expected closing the database to close the breaker")
+	}
+}
+
+func TestOpenConnectorParsesOnce(t *testing.T) {
+	const driver = "wrapper-connector-once"
+	ctx := context.Background()
+	mock, native := newContextMock()
+	if _, err := NewDriver(driver, native); err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open(driver, "once")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if err := db.PingContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+	parsed := atomic.LoadInt32(&mock.connectors)
+
+	// hold several connections at once so the pool has to dial each of them
+	var conns []*sql.Conn
+	for i := 0; i < 3; i++ {
+		conn, err := db.Conn(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conns = append(conns, conn)
+	}
+	for _, conn := range conns {
+		conn.Close()
+	}
+	if n := atomic.LoadInt32(&mock.connectors); n != parsed {
+		t.Fatalf("expected %d inner connectors but got: %d", parsed, n)
+	}
+}
+
+func TestNewConnector(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	open := func(name string) (*BreakerConnector, *sql.DB) {
+		t.Helper()
+		connector, err := NewConnector("sqlite3", filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		db := sql.OpenDB(connector)
+		t.Cleanup(func() { db.Close() })
+		if _, err := db.ExecContext(ctx, "create table users (id integer primary key)"); err != nil {
+			t.Fatal("exec fail:", err)
+		}
+		return connector, db
+	}
+	c1, db1 := open("one.db")
+	c2, db2 := open("two.db")
+
+	c1.Disable(true)
+	if !c1.IsDown() || c2.IsDown() {
+		t.Fatal("expected only the first connector to be down")
+	}
+	if _, err := db1.ExecContext(ctx, "insert into users values(1)"); !errors.Is(err, ErrDown) {
+		t.Fatalf("expected %v but got: %v", ErrDown, err)
+	}
+	if _, err := db2.ExecContext(ctx, "insert into users values(1)"); err != nil {
+		t.Fatal("exec fail:", err)
+	}
+
+	c1.Disable(false)
+	c2.Disable(true)
+	if _, err := db1.ExecContext(ctx, "insert into users values(1)"); err != nil {
+		t.Fatal("exec fail:", err)
+	}
+	if _, err := db2.ExecContext(ctx, "insert into users values(2)"); !errors.Is(err, ErrDown) {
+		t.Fatalf("expected %v but got: %v", ErrDown, err)
+	}
+}
+
+func TestNewConnectorFails(t *testing.T) {
+	const prefix = "connector-fails"
+	errDSN := errors.New("bad dsn")
+	before := runtime.NumGoroutine()
+	_, err := NewConnector("sqlite3", "fails.db",
+		WithDSNRewriter(func(dsn string) (string, error) { return "", errDSN }),
+		WithControlContext(context.Background()),
+		WithHealthProbe(func() bool { return true }, time.Hour),
+		WithExpvar(prefix),
+	)
+	if !errors.Is(err, errDSN) {
+		t.Fatalf("expected %v but got: %v", errDSN, err)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Fatalf("expected no goroutines left running but got %d more", after-before)
+	}
+	if v := expvar.Get(prefix + ".state"); v != nil {
+		t.Fatalf("expected no expvars published but got: %v", v)
+	}
+}
+
+func TestConnectContext(t *testing.T) {
+	const wrapper = "wrapper-connect-context"
+	mock, native := newMock()
+	drv, err := NewDriverWithOptions(wrapper, native, WithFailureThreshold(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	breaker := drv.(*Breaker)
+	connector, err := breaker.OpenConnector("connect-context")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a legacy driver isn't asked to open a connection for a cancelled context
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := connector.Connect(ctx); err != context.Canceled {
+		t.Fatalf("expected %v but got: %v", context.Canceled, err)
+	}
+	if dsns := mock.DSNs(); len(dsns) != 0 {
+		t.Fatalf("expected no connection attempts but got: %v", dsns)
+	}
+	if state := breaker.State(); state != Closed {
+		t.Fatalf("expected the cancellation not to trip the breaker but got: %v", state)
+	}
+
+	// while a connector is given the context to give up with
+	ctxMock, ctxNative := newContextMock()
+	ctxMock.Slow(time.Minute)
+	breaker = newBreaker(ctxNative)
+	defer breaker.Close()
+	connector, err = breaker.OpenConnector("connect-deadline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := connector.Connect(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected %v but got: %v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestConnectorsIndependent(t *testing.T) {
+	const dsn = "shared-dsn"
+	ctx := context.Background()
+	_, native := newMock()
+	connectors := make([]*BreakerConnector, 3)
+	dbs := make([]*sql.DB, 3)
+	changes := make([]atomic.Int32, 3)
+	for i := range connectors {
+		i := i
+		hook := WithStateChangeHook(func(old, new CircuitState) { changes[i].Add(1) })
+		connector, err := NewConnector(native, dsn, hook)
+		if err != nil {
+			t.Fatal(err)
+		}
+		connectors[i] = connector
+		dbs[i] = sql.OpenDB(connector)
+		t.Cleanup(func() { dbs[i].Close() })
+	}
+	exec := func(i int) error {
+		_, err := dbs[i].ExecContext(ctx, "insert into users values(1)")
+		return err
+	}
+
+	connectors[0].Disable(true)
+	connectors[1].DisableName(dsn, true)
+	if err := exec(0); !errors.Is(err, ErrDown) {
+		t.Fatalf("expected %v from the disabled connector but got: %v", ErrDown, err)
+	}
+	if err := exec(1); !errors.Is(err, ErrDown) {
+		t.Fatalf("expected %v from the connector with its name disabled but got: %v", ErrDown, err)
+	}
+	if err := exec(2); err != nil {
+		t.Fatal("exec fail:", err)
+	}
+
+	if n := changes[0].Load(); n != 1 {
+		t.Fatalf("expected 1 state change for the disabled connector but got: %d", n)
+	}
+	if n := changes[1].Load() + changes[2].Load(); n != 0 {
+		t.Fatalf("expected no state changes for the other connectors but got: %d", n)
+	}
+	for i, want := range []uint64{1, 1, 0} {
+		if got := connectors[i].Stats().BlockedOpens + connectors[i].Stats().BlockedExecs; got != want {
+			t.Fatalf("expected %d blocked operations for connector %d but got: %d", want, i, got)
+		}
+	}
+
+	connectors[0].Disable(false)
+	connectors[1].DisableName(dsn, false)
+	connectors[2].Disable(true)
+	for i := 0; i < 2; i++ {
+		if err := exec(i); err != nil {
+			t.Fatalf("exec fail for connector %d: %v", i, err)
+		}
+	}
+	if err := exec(2); !errors.Is(err, ErrDown) {
+		t.Fatalf("expected %v but got: %v", ErrDown, err)
+	}
+}
@@ -0,0 +1,100 @@
+package dbreaker
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestContextCancelReachesDriver(t *testing.T) {
+	type operation struct {
+		name string
+		run  func(ctx context.Context, db *sql.DB, stmt *sql.Stmt) error
+	}
+	exec := operation{"exec", func(ctx context.Context, db *sql.DB, _ *sql.Stmt) error {
+		_, err := db.ExecContext(ctx, "insert into users values(1)")
+		return err
+	}}
+	query := operation{"query", func(ctx context.Context, db *sql.DB, _ *sql.Stmt) error {
+		rows, err := db.QueryContext(ctx, "select * from users")
+		if err == nil {
+			rows.Close()
+		}
+		return err
+	}}
+	prepare := operation{"prepare", func(ctx context.Context, db *sql.DB, _ *sql.Stmt) error {
+		_, err := db.PrepareContext(ctx, "select * from users")
+		return err
+	}}
+	begin := operation{"begin", func(ctx context.Context, db *sql.DB, _ *sql.Stmt) error {
+		_, err := db.BeginTx(ctx, nil)
+		return err
+	}}
+	stmtExec := operation{"statement exec", func(ctx context.Context, _ *sql.DB, stmt *sql.Stmt) error {
+		_, err := stmt.ExecContext(ctx)
+		return err
+	}}
+	stmtQuery := operation{"statement query", func(ctx context.Context, _ *sql.DB, stmt *sql.Stmt) error {
+		rows, err := stmt.QueryContext(ctx)
+		if err == nil {
+			rows.Close()
+		}
+		return err
+	}}
+
+	for _, tt := range []struct {
+		wrapper string
+		legacy  bool
+		ops     []operation
+	}{
+		{"wrapper-cancel", false, []operation{exec, query, prepare, begin, stmtExec, stmtQuery}},
+		// execs and queries fall back to being prepared with the context
+		{"wrapper-cancel-legacy", true, []operation{exec, query, prepare, stmtExec, stmtQuery}},
+	} {
+		wait, native := newWaitMock(t, tt.legacy)
+		if _, err := NewDriver(tt.wrapper, native); err != nil {
+			t.Fatal(err)
+		}
+		db, err := sql.Open(tt.wrapper, "cancel")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		stmt, err := db.Prepare("prepared")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer stmt.Close()
+
+		for _, op := range tt.ops {
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan error, 1)
+			go func() { done <- op.run(ctx, db, stmt) }()
+			deadline := time.Now().Add(time.Second)
+			for wait.waiting.Load() == 0 {
+				if time.Now().After(deadline) {
+					t.Fatalf("%s %s: expected the operation to reach the driver", tt.wrapper, op.name)
+				}
+				time.Sleep(time.Millisecond)
+			}
+
+			cancel()
+			select {
+			case err := <-done:
+				if !errors.Is(err, context.Canceled) {
+					t.Fatalf("%s %s: expected %v but got: %v", tt.wrapper, op.name, context.Canceled, err)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("%s %s: expected the operation to return once canceled", tt.wrapper, op.name)
+			}
+			for wait.waiting.Load() != 0 {
+				if time.Now().After(deadline) {
+					t.Fatalf("%s %s: expected the driver to stop waiting", tt.wrapper, op.name)
+				}
+				time.Sleep(time.Millisecond)
+			}
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package dbreaker
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// watchGroup is a sync.WaitGroup of background goroutines that a goroutine
+// can step out of while it runs state change hooks, so that a hook waiting
+// on the group, as Close does, doesn't wait for the goroutine running it
+type watchGroup struct {
+	mu   sync.Mutex
+	cond sync.Cond
+	n    int
+}
+
+// Add adds delta to the count of goroutines
+func (g *watchGroup) Add(delta int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.n += delta
+	if g.n <= 0 && g.cond.L != nil {
+		g.cond.Broadcast()
+	}
+}
+
+// Done takes a goroutine off the count
+func (g *watchGroup) Done() {
+	g.Add(-1)
+}
+
+// Wait waits for the count to drop to zero
+func (g *watchGroup) Wait() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.cond.L == nil {
+		g.cond.L = &g.mu
+	}
+	for g.n > 0 {
+		g.cond.Wait()
+	}
+}
+
+// aside runs fn with the calling goroutine left out of the count
+func (g *watchGroup) aside(fn func()) {
+	g.Done()
+	defer g.Add(1)
+	fn()
+}
+
+// notifyAside is notify for background goroutines, which Close doesn't
+// wait for while they run the state change hooks
+func (w *Breaker) notifyAside() {
+	w.watchers.aside(func() { w.notify() })
+}
+
+// watch takes the breaker down once ctx is done, until the breaker is closed
+func (w *Breaker) watch(ctx context.Context) {
+	defer w.watchers.Done()
+	select {
+	case <-ctx.Done():
+		w.lost.Store(true)
+		w.notifyAside()
+	case <-w.quit:
+	}
+}
+
+// pollHealth takes the breaker down while fn reports false, calling it every
+// interval until the breaker is closed. A panic in fn counts as false.
+func (w *Breaker) pollHealth(fn func() bool, interval time.Duration) {
+	defer w.watchers.Done()
+	for {
+		healthy := false
+		w.guard("health probe", func() { healthy = fn() })
+		if w.sick.Swap(!healthy) == healthy {
+			w.notifyAside()
+		}
+		select {
+		case <-w.clock.After(interval):
+		case <-w.quit:
+			return
+		}
+	}
+}
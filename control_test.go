@@ -0,0 +1,144 @@
+package dbreaker
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestControlContext(t *testing.T) {
+	const driver = "wrapper-control"
+	ctx, cancel := context.WithCancel(context.Background())
+	_, native := newMock()
+	drv, err := NewDriverWithOptions(driver, native, WithControlContext(ctx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	breaker := drv.(*Breaker)
+	if _, err := breaker.Open("control"); err != nil {
+		t.Fatal(err)
+	}
+
+	changed := make(chan CircuitState, 1)
+	breaker.OnStateChange(func(old, new CircuitState) { changed <- new })
+	cancel()
+	select {
+	case state := <-changed:
+		if state != Open {
+			t.Fatalf("expected state %v but got: %v", Open, state)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the breaker to open when the control context is done")
+	}
+	if _, err := breaker.Open("control"); !errors.Is(err, ErrDown) {
+		t.Fatalf("expected %v but got: %v", ErrDown, err)
+	}
+
+	// it stays open
+	breaker.Disable(false)
+	if _, err := breaker.Open("control"); !errors.Is(err, ErrDown) {
+		t.Fatalf("expected %v after Disable(false) but got: %v", ErrDown, err)
+	}
+}
+
+func TestControlContextClose(t *testing.T) {
+	_, native := newMock()
+	breaker := newBreaker(native, WithControlContext(context.Background()))
+	closed := make(chan error, 1)
+	go func() { closed <- breaker.Close() }()
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected Close to stop the control context watcher")
+	}
+	if breaker.IsDown() {
+		t.Fatal("expected closing the breaker not to count as the control context being done")
+	}
+}
+
+func TestControlContextHookClose(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	_, native := newMock()
+	breaker := newBreaker(native, WithControlContext(ctx))
+	closed := make(chan error, 1)
+	breaker.OnStateChange(func(old, new CircuitState) { closed <- breaker.Close() })
+
+	// the hook is run by the goroutine watching the context
+	cancel()
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected a hook run by the control context watcher to be able to close the breaker")
+	}
+}
+
+func TestControlContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, native := newMock()
+	breaker := newBreaker(native, WithControlContext(ctx))
+	defer breaker.Close()
+	if !breaker.IsDown() {
+		t.Fatal("expected a breaker with its control context done to start down")
+	}
+	if state := breaker.State(); state != Open {
+		t.Fatalf("expected state %v but got: %v", Open, state)
+	}
+	if _, err := breaker.Open("control-done"); !errors.Is(err, ErrDown) {
+		t.Fatalf("expected %v but got: %v", ErrDown, err)
+	}
+}
+
+func TestHealthProbe(t *testing.T) {
+	clk := newFakeClock()
+	_, native := newMock()
+	var healthy atomic.Bool
+	healthy.Store(true)
+	breaker := newBreaker(native, WithClock(clk), WithHealthProbe(healthy.Load, 10*time.Second))
+	defer breaker.Close()
+
+	// poll waits for the probe to be called again after interval
+	poll := func() {
+		t.Helper()
+		clk.Advance(10 * time.Second)
+		clk.BlockUntil(1)
+	}
+	clk.BlockUntil(1)
+	if state := breaker.State(); state != Closed {
+		t.Fatalf("expected state %v but got: %v", Closed, state)
+	}
+
+	healthy.Store(false)
+	poll()
+	if state := breaker.State(); state != Open {
+		t.Fatalf("expected state %v once unhealthy but got: %v", Open, state)
+	}
+	if _, err := breaker.Open("health-probe"); !errors.Is(err, ErrDown) {
+		t.Fatalf("expected %v but got: %v", ErrDown, err)
+	}
+	breaker.Disable(false)
+	if !breaker.IsDown() {
+		t.Fatal("expected the breaker to stay down while unhealthy")
+	}
+
+	healthy.Store(true)
+	poll()
+	if state := breaker.State(); state != Closed {
+		t.Fatalf("expected state %v once healthy but got: %v", Closed, state)
+	}
+
+	// it is down by hand or by the probe
+	breaker.Disable(true)
+	poll()
+	if !breaker.IsDown() {
+		t.Fatal("expected the breaker to stay disabled while healthy")
+	}
+}
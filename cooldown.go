@@ -0,0 +1,86 @@
+package dbreaker
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// cooldowns tracks the names whose Opens fail fast after one failed,
+// see WithOpenCooldown
+type cooldowns struct {
+	mu    sync.Mutex
+	names map[string]*cooldown
+}
+
+// cooldown is the state of a name cooling down after a failed Open
+type cooldown struct {
+	until   time.Time // when a probe may next try to connect
+	probing bool      // a probe is trying to connect
+}
+
+// admit reports whether an Open of name may connect as of now, and whether
+// it is the single probe let through once the cooldown has passed
+func (c *cooldowns) admit(name string, now time.Time) (ok, probe bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cd := c.names[name]
+	if cd == nil {
+		return true, false
+	}
+	if cd.probing || now.Before(cd.until) {
+		return false, false
+	}
+	cd.probing = true
+	return true, true
+}
+
+// record ends the cooldown of name once an Open succeeds, or starts it
+// over for d if failed
+func (c *cooldowns) record(name string, failed bool, now time.Time, d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !failed {
+		delete(c.names, name)
+		return
+	}
+	if c.names == nil {
+		c.names = make(map[string]*cooldown)
+	}
+	c.names[name] = &cooldown{until: now.Add(d)}
+}
+
+// abort lets another probe through for name, as this one did not connect
+func (c *cooldowns) abort(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cd := c.names[name]; cd != nil {
+		cd.probing = false
+	}
+}
+
+// chill reports whether an Open of name is to fail fast as name is cooling
+// down after a failed Open, see WithOpenCooldown, and if not whether it is
+// the probe let through once the cooldown has passed
+func (w *Breaker) chill(ctx context.Context, name string) (refused, probe bool) {
+	if w.cooldown <= 0 || bypassed(ctx) {
+		return false, false
+	}
+	ok, probe := w.cooling.admit(name, w.clock.Now())
+	return !ok, probe
+}
+
+// settle records the outcome of an Open of name let through by chill.
+// Failures the database is not to blame for, as ctx gave up on them or
+// the failure classifier does not count them, leave the cooldown as is.
+func (w *Breaker) settle(ctx context.Context, name string, probe bool, err error) {
+	switch {
+	case w.cooldown <= 0:
+	case err == nil:
+		w.cooling.record(name, false, w.clock.Now(), w.cooldown)
+	case ctx.Err() == nil && (w.failing == nil || w.counts(err)):
+		w.cooling.record(name, true, w.clock.Now(), w.cooldown)
+	case probe:
+		w.cooling.abort(name)
+	}
+}
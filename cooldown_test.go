@@ -0,0 +1,84 @@
+package dbreaker
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/paulstuart/dbreaker/dbreakertest"
+)
+
+func TestOpenCooldown(t *testing.T) {
+	const wrapper = "wrapper-open-cooldown"
+	ctx := context.Background()
+	clk := newFakeClock()
+	fake, native := dbreakertest.Register()
+	drv, err := NewDriverWithOptions(wrapper, native, WithClock(clk), WithOpenCooldown(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	breaker := drv.(*Breaker)
+	db, err := sql.Open(wrapper, "cooldown")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxIdleConns(0)
+
+	unreachable := errors.New("connection refused")
+	fake.Fail(dbreakertest.Open, unreachable)
+	if err := db.PingContext(ctx); !errors.Is(err, unreachable) {
+		t.Fatalf("expected %v but got: %v", unreachable, err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := db.PingContext(ctx); !errors.Is(err, ErrDown) {
+			t.Fatalf("expected %v during the cooldown but got: %v", ErrDown, err)
+		}
+	}
+	if n := fake.Calls(dbreakertest.Open); n != 1 {
+		t.Fatalf("expected a single open but got: %d", n)
+	}
+	if state := breaker.State(); state != Closed {
+		t.Fatalf("expected state %v but got: %v", Closed, state)
+	}
+
+	// a failed probe starts the cooldown over
+	clk.Advance(time.Minute)
+	if err := db.PingContext(ctx); !errors.Is(err, unreachable) {
+		t.Fatalf("expected the probe to fail with %v but got: %v", unreachable, err)
+	}
+	if err := db.PingContext(ctx); !errors.Is(err, ErrDown) {
+		t.Fatalf("expected %v after the failed probe but got: %v", ErrDown, err)
+	}
+
+	// others fail fast while the probe is connecting
+	clk.Advance(time.Minute)
+	fake.Fail(dbreakertest.Open, nil)
+	release := fake.Block(dbreakertest.Open)
+	probed := make(chan error, 1)
+	go func() { probed <- db.PingContext(ctx) }()
+	deadline := time.Now().Add(time.Second)
+	for fake.Calls(dbreakertest.Open) != 3 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the probe to reach the driver")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := db.PingContext(ctx); !errors.Is(err, ErrDown) {
+		t.Fatalf("expected %v while probing but got: %v", ErrDown, err)
+	}
+	release()
+	if err := <-probed; err != nil {
+		t.Fatalf("expected the probe to connect but got: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := db.PingContext(ctx); err != nil {
+			t.Fatalf("expected the cooldown to be over but got: %v", err)
+		}
+	}
+	if n := breaker.Stats().BlockedOpens; n != 5 {
+		t.Fatalf("expected 5 blocked opens but got: %d", n)
+	}
+}
@@ -0,0 +1,81 @@
+// Package dbreakermetrics exports dbreaker state and counters to Prometheus
+//
+// It is a separate package so that dbreaker itself does not depend on
+// the Prometheus client.
+package dbreakermetrics
+
+import (
+	"github.com/paulstuart/dbreaker"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	stateDesc = prometheus.NewDesc(
+		"dbreaker_state",
+		"Current state of the breaker (0=closed, 1=open, 2=half-open).",
+		[]string{"breaker"}, nil,
+	)
+	allowedDesc = prometheus.NewDesc(
+		"dbreaker_allowed_opens_total",
+		"Connections let through to the inner driver.",
+		[]string{"breaker"}, nil,
+	)
+	blockedDesc = prometheus.NewDesc(
+		"dbreaker_blocked_total",
+		"Operations refused by the breaker.",
+		[]string{"breaker", "op"}, nil,
+	)
+	tripsDesc = prometheus.NewDesc(
+		"dbreaker_trips_total",
+		"Times the breaker tripped automatically.",
+		[]string{"breaker"}, nil,
+	)
+)
+
+// Collector is a prometheus.Collector for one or more breakers.
+//
+// Counters are taken from Breaker.Stats, so calling ResetStats on a
+// collected breaker looks like a counter reset to Prometheus.
+type Collector struct {
+	breakers map[string]*dbreaker.Breaker
+}
+
+// NewCollector returns a collector for breakers, labelled by their map key
+func NewCollector(breakers map[string]*dbreaker.Breaker) *Collector {
+	c := &Collector{breakers: make(map[string]*dbreaker.Breaker, len(breakers))}
+	for name, b := range breakers {
+		c.breakers[name] = b
+	}
+	return c
+}
+
+// NewNamedCollector returns a collector for breakers, labelled by their names,
+// see dbreaker.WithName
+func NewNamedCollector(breakers ...*dbreaker.Breaker) *Collector {
+	c := &Collector{breakers: make(map[string]*dbreaker.Breaker, len(breakers))}
+	for _, b := range breakers {
+		c.breakers[b.Name()] = b
+	}
+	return c
+}
+
+// Describe satisfies the prometheus.Collector interface
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- stateDesc
+	ch <- allowedDesc
+	ch <- blockedDesc
+	ch <- tripsDesc
+}
+
+// Collect satisfies the prometheus.Collector interface
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	for name, b := range c.breakers {
+		stats := b.Stats()
+		ch <- prometheus.MustNewConstMetric(stateDesc, prometheus.GaugeValue, float64(b.State()), name)
+		ch <- prometheus.MustNewConstMetric(allowedDesc, prometheus.CounterValue, float64(stats.AllowedOpens), name)
+		ch <- prometheus.MustNewConstMetric(blockedDesc, prometheus.CounterValue, float64(stats.BlockedOpens), name, "open")
+		ch <- prometheus.MustNewConstMetric(blockedDesc, prometheus.CounterValue, float64(stats.BlockedQueries), name, "query")
+		ch <- prometheus.MustNewConstMetric(blockedDesc, prometheus.CounterValue, float64(stats.BlockedExecs), name, "exec")
+		ch <- prometheus.MustNewConstMetric(tripsDesc, prometheus.CounterValue, float64(stats.Trips), name)
+	}
+}
@@ -0,0 +1,69 @@
+package dbreakermetrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/paulstuart/dbreaker"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestCollector(t *testing.T) {
+	primary, err := dbreaker.NewDriver("metrics-primary", "sqlite3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	replica, err := dbreaker.NewDriver("metrics-replica", "sqlite3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	primary.Disable(true)
+	for i := 0; i < 2; i++ {
+		primary.Open(":memory:")
+	}
+
+	c := NewCollector(map[string]*dbreaker.Breaker{
+		"primary": primary.(*dbreaker.Breaker),
+		"replica": replica.(*dbreaker.Breaker),
+	})
+	const expect = `
+# HELP dbreaker_blocked_total Operations refused by the breaker.
+# TYPE dbreaker_blocked_total counter
+dbreaker_blocked_total{breaker="primary",op="exec"} 0
+dbreaker_blocked_total{breaker="primary",op="open"} 2
+dbreaker_blocked_total{breaker="primary",op="query"} 0
+dbreaker_blocked_total{breaker="replica",op="exec"} 0
+dbreaker_blocked_total{breaker="replica",op="open"} 0
+dbreaker_blocked_total{breaker="replica",op="query"} 0
+# HELP dbreaker_state Current state of the breaker (0=closed, 1=open, 2=half-open).
+# TYPE dbreaker_state gauge
+dbreaker_state{breaker="primary"} 1
+dbreaker_state{breaker="replica"} 0
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expect), "dbreaker_state", "dbreaker_blocked_total"); err != nil {
+		t.Fatal(err)
+	}
+	if n := testutil.CollectAndCount(c); n != 12 {
+		t.Fatalf("expected 12 metrics but got: %d", n)
+	}
+}
+
+func TestNamedCollector(t *testing.T) {
+	drv, err := dbreaker.NewDriverWithOptions("metrics-named", "sqlite3", dbreaker.WithName("orders"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	drv.Disable(true)
+
+	c := NewNamedCollector(drv.(*dbreaker.Breaker))
+	const expect = `
+# HELP dbreaker_state Current state of the breaker (0=closed, 1=open, 2=half-open).
+# TYPE dbreaker_state gauge
+dbreaker_state{breaker="orders"} 1
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expect), "dbreaker_state"); err != nil {
+		t.Fatal(err)
+	}
+}
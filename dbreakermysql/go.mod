@@ -0,0 +1,8 @@
+module github.com/paulstuart/dbreaker/dbreakermysql
+
+go 1.21
+
+require (
+	github.com/go-sql-driver/mysql v1.7.1
+	github.com/paulstuart/dbreaker v0.0.0-20261014150134-713ddf9c137a
+)
@@ -0,0 +1,58 @@
+// Package dbreakermysql classifies MySQL errors for dbreaker, so that only
+// failures of the database server or the connection to it trip the breaker
+//
+// It is a separate package so that dbreaker itself does not depend on
+// the MySQL driver.
+package dbreakermysql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"net"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/paulstuart/dbreaker"
+)
+
+// codes are the numbers of MySQL errors that are failures of the server
+// or the connection to it
+var codes = map[uint16]bool{
+	1040: true, // ER_CON_COUNT_ERROR, too many connections
+	1053: true, // ER_SERVER_SHUTDOWN
+	1152: true, // ER_ABORTING_CONNECTION
+	1158: true, // ER_NET_READ_ERROR
+	1159: true, // ER_NET_READ_INTERRUPTED
+	1160: true, // ER_NET_ERROR_ON_WRITE
+	1161: true, // ER_NET_WRITE_INTERRUPTED
+	1927: true, // ER_CONNECTION_KILLED
+	2002: true, // CR_CONNECTION_ERROR
+	2003: true, // CR_CONN_HOST_ERROR
+	2006: true, // CR_SERVER_GONE_ERROR
+	2013: true, // CR_SERVER_LOST
+}
+
+// MySQLClassifier reports whether err is a failure of the database server
+// or the connection to it, for dbreaker.WithFailureClassifier. These are
+// MySQL errors such as too many connections, server shutdown, the client
+// errors 2002 and 2003 of failing to connect and 2006 and 2013 of losing
+// the server, as well as mysql.ErrInvalidConn, driver.ErrBadConn, network
+// errors and expired deadlines. Errors in the statement or its data, such
+// as syntax errors or duplicate keys, do not count.
+func MySQLClassifier(err error) bool {
+	var myErr *mysql.MySQLError
+	if errors.As(err, &myErr) {
+		return codes[myErr.Number]
+	}
+	var netErr net.Error
+	return errors.Is(err, mysql.ErrInvalidConn) ||
+		errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		errors.As(err, &netErr)
+}
+
+// Option returns the dbreaker option counting only the failures reported
+// by MySQLClassifier toward tripping the breaker
+func Option() dbreaker.Option {
+	return dbreaker.WithFailureClassifier(MySQLClassifier)
+}
@@ -0,0 +1,40 @@
+package dbreakermysql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestMySQLClassifier(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"connection error", &mysql.MySQLError{Number: 2002}, true},
+		{"host error", &mysql.MySQLError{Number: 2003}, true},
+		{"server gone", &mysql.MySQLError{Number: 2006}, true},
+		{"server lost", &mysql.MySQLError{Number: 2013}, true},
+		{"too many connections", &mysql.MySQLError{Number: 1040}, true},
+		{"shutdown", &mysql.MySQLError{Number: 1053}, true},
+		{"wrapped", fmt.Errorf("query users: %w", &mysql.MySQLError{Number: 2003}), true},
+		{"syntax error", &mysql.MySQLError{Number: 1064}, false},
+		{"duplicate key", &mysql.MySQLError{Number: 1062}, false},
+		{"invalid conn", mysql.ErrInvalidConn, true},
+		{"bad conn", driver.ErrBadConn, true},
+		{"deadline", context.DeadlineExceeded, true},
+		{"refused", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, true},
+		{"other", errors.New("no rows"), false},
+	}
+	for _, tt := range tests {
+		if got := MySQLClassifier(tt.err); got != tt.want {
+			t.Errorf("%s: expected %v but got: %v", tt.name, tt.want, got)
+		}
+	}
+}
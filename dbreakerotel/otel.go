@@ -0,0 +1,94 @@
+// Package dbreakerotel traces the decisions of a dbreaker.Breaker with OpenTelemetry
+//
+// It is a separate package so that dbreaker itself does not depend on
+// OpenTelemetry. Hooks plug into a breaker through its callbacks:
+//
+//	hooks := dbreakerotel.New(otel.GetTracerProvider())
+//	db, breaker, err := dbreaker.WrapDB("postgres", dsn, hooks.Options()...)
+package dbreakerotel
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/paulstuart/dbreaker"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentation is the name of the tracer spans are made with
+const instrumentation = "github.com/paulstuart/dbreaker/dbreakerotel"
+
+// attribute keys of the spans
+const (
+	StateKey     = attribute.Key("dbreaker.state")
+	BlockedKey   = attribute.Key("dbreaker.blocked")
+	OpKey        = attribute.Key("dbreaker.op")
+	DSNKey       = attribute.Key("dbreaker.dsn")
+	StatementKey = attribute.Key("db.statement")
+)
+
+// Hooks creates spans for the statements a breaker gates and the operations
+// it refuses. A Hooks follows the state of a single breaker, so each breaker
+// needs one of its own.
+type Hooks struct {
+	tracer trace.Tracer
+	state  atomic.Int32 // dbreaker.CircuitState as last reported by the breaker
+}
+
+// New returns Hooks creating spans with a tracer of tp
+func New(tp trace.TracerProvider) *Hooks {
+	return &Hooks{tracer: tp.Tracer(instrumentation)}
+}
+
+// Options returns the options to give the breaker to trace it. They set its
+// query interceptor and blocked hook, use Intercept and Blocked directly to
+// combine them with callbacks of your own.
+func (h *Hooks) Options() []dbreaker.Option {
+	return []dbreaker.Option{
+		dbreaker.WithStateChangeHook(h.StateChanged),
+		dbreaker.WithQueryInterceptor(h.Intercept),
+		dbreaker.WithOnBlocked(h.Blocked),
+	}
+}
+
+// StateChanged follows the state of the breaker, for dbreaker.WithStateChangeHook
+func (h *Hooks) StateChanged(old, new dbreaker.CircuitState) {
+	h.state.Store(int32(new))
+}
+
+// Intercept records a dbreaker.statement span as a child of any span in ctx
+// for each statement the breaker gates, with the statement and the state of
+// the breaker as it was screened, for dbreaker.WithQueryInterceptor. It never
+// vetoes the statement.
+func (h *Hooks) Intercept(ctx context.Context, query string) error {
+	_, span := h.tracer.Start(ctx, "dbreaker.statement", trace.WithAttributes(
+		StatementKey.String(query),
+		StateKey.String(h.current().String()),
+	))
+	span.End()
+	return nil
+}
+
+// Blocked records a dbreaker.blocked span for each operation the breaker
+// refuses, with the operation, the fingerprint of the statement if any, the
+// data source name and the state of the breaker, for dbreaker.WithOnBlocked.
+// As the breaker gives the hook no context the spans have no parent.
+func (h *Hooks) Blocked(op, query, dsn string) {
+	attrs := []attribute.KeyValue{
+		OpKey.String(op),
+		DSNKey.String(dsn),
+		StateKey.String(h.current().String()),
+		BlockedKey.Bool(true),
+	}
+	if query != "" {
+		attrs = append(attrs, StatementKey.String(query))
+	}
+	_, span := h.tracer.Start(context.Background(), "dbreaker.blocked", trace.WithAttributes(attrs...))
+	span.End()
+}
+
+// current returns the state of the breaker
+func (h *Hooks) current() dbreaker.CircuitState {
+	return dbreaker.CircuitState(h.state.Load())
+}
@@ -0,0 +1,95 @@
+package dbreakerotel
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/paulstuart/dbreaker"
+	"github.com/paulstuart/dbreaker/dbreakertest"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+var errMock = errors.New("mock failure")
+
+func TestHooks(t *testing.T) {
+	ctx := context.Background()
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	hooks := New(tp)
+	fake, native := dbreakertest.Register()
+	opts := append(hooks.Options(), dbreaker.WithFailureThreshold(1))
+	db, _, err := dbreaker.WrapDB(native, "otel", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// a single connection sees the exec refused, rather than a new connection
+	ctx, parent := tp.Tracer("test").Start(ctx, "request")
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, "insert into users values(1)"); err != nil {
+		t.Fatal(err)
+	}
+	fake.Fail(dbreakertest.Exec, errMock)
+	if _, err := conn.ExecContext(ctx, "insert into users values(2)"); err != errMock {
+		t.Fatalf("expected %v but got: %v", errMock, err)
+	}
+	if _, err := conn.ExecContext(ctx, "insert into users values(3)"); !errors.Is(err, dbreaker.ErrDown) {
+		t.Fatalf("expected %v but got: %v", dbreaker.ErrDown, err)
+	}
+	parent.End()
+
+	type span struct {
+		name  string
+		attrs map[attribute.Key]attribute.Value
+	}
+	var statements, blocked []span
+	for _, s := range recorder.Ended() {
+		attrs := make(map[attribute.Key]attribute.Value)
+		for _, kv := range s.Attributes() {
+			attrs[kv.Key] = kv.Value
+		}
+		switch s.Name() {
+		case "dbreaker.statement":
+			if s.Parent().SpanID() != parent.SpanContext().SpanID() {
+				t.Fatalf("expected statement spans to be children of the request")
+			}
+			statements = append(statements, span{s.Name(), attrs})
+		case "dbreaker.blocked":
+			blocked = append(blocked, span{s.Name(), attrs})
+		}
+	}
+
+	if len(statements) != 3 {
+		t.Fatalf("expected 3 statement spans but got: %d", len(statements))
+	}
+	for i, state := range []string{"closed", "closed", "open"} {
+		if got := statements[i].attrs[StateKey].AsString(); got != state {
+			t.Fatalf("expected statement %d to see the breaker %s but got: %s", i, state, got)
+		}
+	}
+	if got := statements[2].attrs[StatementKey].AsString(); got != "insert into users values(3)" {
+		t.Fatalf("expected the statement to be recorded but got: %q", got)
+	}
+
+	if len(blocked) != 1 {
+		t.Fatalf("expected 1 blocked span but got: %d", len(blocked))
+	}
+	attrs := blocked[0].attrs
+	if op := attrs[OpKey].AsString(); op != "exec" {
+		t.Fatalf("expected the exec to be blocked but got: %s", op)
+	}
+	if !attrs[BlockedKey].AsBool() || attrs[StateKey].AsString() != "open" || attrs[DSNKey].AsString() != "otel" {
+		t.Fatalf("expected a blocked span for otel while open but got: %v", attrs)
+	}
+	if got := attrs[StatementKey].AsString(); got != "insert into users values (?)" {
+		t.Fatalf("expected the fingerprint of the statement but got: %q", got)
+	}
+}
@@ -0,0 +1,8 @@
+module github.com/paulstuart/dbreaker/dbreakerpg
+
+go 1.21
+
+require (
+	github.com/lib/pq v1.10.9
+	github.com/paulstuart/dbreaker v0.0.0-20261014150134-713ddf9c137a
+)
@@ -0,0 +1,57 @@
+// Package dbreakerpg classifies Postgres errors for dbreaker, so that only
+// failures of the database server or the connection to it trip the breaker
+//
+// It is a separate package so that dbreaker itself knows nothing of
+// Postgres. It matches errors by their SQLSTATE code, so it works with
+// any driver whose errors report it, including lib/pq and pgx.
+package dbreakerpg
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"net"
+
+	"github.com/paulstuart/dbreaker"
+)
+
+// codes are the SQLSTATE codes of failures of the server, besides those
+// of class 08, connection exception
+var codes = map[string]bool{
+	"53300": true, // too_many_connections
+	"57P01": true, // admin_shutdown
+	"57P02": true, // crash_shutdown
+	"57P03": true, // cannot_connect_now
+	"58030": true, // io_error
+}
+
+// sqlState is implemented by the errors of Postgres drivers
+type sqlState interface {
+	SQLState() string
+}
+
+// PostgresClassifier reports whether err is a failure of the database
+// server or the connection to it, for dbreaker.WithFailureClassifier.
+// These are errors with an SQLSTATE code of class 08 (connection
+// exception) or one of too_many_connections, admin_shutdown,
+// crash_shutdown, cannot_connect_now and io_error, as well as
+// driver.ErrBadConn, network errors and expired deadlines.
+// Errors in the statement or its data, such as syntax errors or
+// constraint violations, do not count.
+func PostgresClassifier(err error) bool {
+	var state sqlState
+	if errors.As(err, &state) {
+		code := state.SQLState()
+		return len(code) == 5 && code[:2] == "08" || codes[code]
+	}
+	var netErr net.Error
+	return errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		errors.As(err, &netErr)
+}
+
+// Option returns the dbreaker option counting only the failures reported
+// by PostgresClassifier toward tripping the breaker
+func Option() dbreaker.Option {
+	return dbreaker.WithFailureClassifier(PostgresClassifier)
+}
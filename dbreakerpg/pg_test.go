@@ -0,0 +1,47 @@
+package dbreakerpg
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+// pgxError reports its code like pgconn.PgError
+type pgxError struct{ code string }
+
+func (e *pgxError) Error() string    { return "ERROR: " + e.code }
+func (e *pgxError) SQLState() string { return e.code }
+
+func TestPostgresClassifier(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"cannot connect now", &pq.Error{Code: "57P03"}, true},
+		{"admin shutdown", &pq.Error{Code: "57P01"}, true},
+		{"crash shutdown", &pq.Error{Code: "57P02"}, true},
+		{"too many connections", &pq.Error{Code: "53300"}, true},
+		{"connection failure", &pq.Error{Code: "08006"}, true},
+		{"connection exception", &pq.Error{Code: "08000"}, true},
+		{"wrapped", fmt.Errorf("query users: %w", &pq.Error{Code: "57P03"}), true},
+		{"pgx", &pgxError{code: "08001"}, true},
+		{"syntax error", &pq.Error{Code: "42601"}, false},
+		{"unique violation", &pq.Error{Code: "23505"}, false},
+		{"pgx constraint", &pgxError{code: "23503"}, false},
+		{"bad conn", driver.ErrBadConn, true},
+		{"deadline", context.DeadlineExceeded, true},
+		{"refused", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, true},
+		{"other", errors.New("no rows"), false},
+	}
+	for _, tt := range tests {
+		if got := PostgresClassifier(tt.err); got != tt.want {
+			t.Errorf("%s: expected %v but got: %v", tt.name, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+// Package dbreakertest provides a fake database driver for testing code that uses dbreaker
+//
+// The driver keeps no data. Its connections accept any statement, queries
+// return no rows, and each kind of operation can be made to fail or block
+// on demand so the breaker can be driven through its states without a
+// real database.
+package dbreakertest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// Op is a kind of operation on the driver
+type Op string
+
+// operations that can be made to fail or block
+const (
+	Open  Op = "open"
+	Exec  Op = "exec"
+	Query Op = "query"
+	Begin Op = "begin"
+)
+
+var registered int32
+
+// Driver is a fake driver.Driver whose operations can fail or block on demand
+type Driver struct {
+	mu    sync.Mutex
+	fail  map[Op]error
+	gates map[Op]*gate
+	calls map[Op]int
+}
+
+// gate holds calls of a blocked operation until it is opened
+type gate struct {
+	ch   chan struct{}
+	once sync.Once
+}
+
+func (g *gate) open() {
+	g.once.Do(func() { close(g.ch) })
+}
+
+// New returns a Driver that is not registered with the sql package
+func New() *Driver {
+	return &Driver{
+		fail:  make(map[Op]error),
+		gates: make(map[Op]*gate),
+		calls: make(map[Op]int),
+	}
+}
+
+// Register registers a new Driver under a unique name and returns both,
+// the name can be passed as the native driver to dbreaker.NewDriver
+func Register() (*Driver, string) {
+	name := fmt.Sprintf("dbreakertest%d", atomic.AddInt32(&registered, 1))
+	d := New()
+	sql.Register(name, d)
+	return d, name
+}
+
+// Fail makes subsequent calls of op return err, nil restores normal operation
+func (d *Driver) Fail(op Op, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err == nil {
+		delete(d.fail, op)
+	} else {
+		d.fail[op] = err
+	}
+}
+
+// Block makes subsequent calls of op wait until release is called,
+// or until their context is done for operations that take one.
+// It is safe to call release more than once.
+func (d *Driver) Block(op Op) (release func()) {
+	g := &gate{ch: make(chan struct{})}
+	d.mu.Lock()
+	d.gates[op] = g
+	d.mu.Unlock()
+	return func() {
+		d.mu.Lock()
+		if d.gates[op] == g {
+			delete(d.gates, op)
+		}
+		d.mu.Unlock()
+		g.open()
+	}
+}
+
+// Calls returns the number of times op has been called, including failed calls
+func (d *Driver) Calls(op Op) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.calls[op]
+}
+
+// Reset clears all failures and call counts and releases blocked calls
+func (d *Driver) Reset() {
+	d.mu.Lock()
+	gates := d.gates
+	d.fail = make(map[Op]error)
+	d.gates = make(map[Op]*gate)
+	d.calls = make(map[Op]int)
+	d.mu.Unlock()
+	for _, g := range gates {
+		g.open()
+	}
+}
+
+// call counts a call of op, waits while it is blocked and returns its failure, if any
+func (d *Driver) call(ctx context.Context, op Op) error {
+	d.mu.Lock()
+	d.calls[op]++
+	g := d.gates[op]
+	d.mu.Unlock()
+	if g != nil {
+		select {
+		case <-g.ch:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.fail[op]
+}
+
+// Open satisfies the sql.Driver interface
+func (d *Driver) Open(name string) (driver.Conn, error) {
+	if err := d.call(context.Background(), Open); err != nil {
+		return nil, err
+	}
+	return &conn{d: d}, nil
+}
+
+type conn struct {
+	d *Driver
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return &stmt{c: c}, nil
+}
+
+func (c *conn) Close() error { return nil }
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if err := c.d.call(ctx, Begin); err != nil {
+		return nil, err
+	}
+	return tx{}, nil
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if err := c.d.call(ctx, Exec); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if err := c.d.call(ctx, Query); err != nil {
+		return nil, err
+	}
+	return rows{}, nil
+}
+
+type stmt struct {
+	c *conn
+}
+
+func (s *stmt) Close() error  { return nil }
+func (s *stmt) NumInput() int { return -1 }
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), nil)
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), nil)
+}
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.c.ExecContext(ctx, "", args)
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.c.QueryContext(ctx, "", args)
+}
+
+type tx struct{}
+
+func (tx) Commit() error   { return nil }
+func (tx) Rollback() error { return nil }
+
+// rows is an empty result set
+type rows struct{}
+
+func (rows) Columns() []string              { return nil }
+func (rows) Close() error                   { return nil }
+func (rows) Next(dest []driver.Value) error { return io.EOF }
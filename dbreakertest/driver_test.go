@@ -0,0 +1,90 @@
+package dbreakertest_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/paulstuart/dbreaker"
+	"github.com/paulstuart/dbreaker/dbreakertest"
+)
+
+var errOutage = errors.New("connection refused")
+
+func TestTripAndRecover(t *testing.T) {
+	const (
+		wrapper = "dbreakertest-trip"
+		timeout = 10 * time.Millisecond
+	)
+	fake, native := dbreakertest.Register()
+	drv, err := dbreaker.NewDriverWithOptions(wrapper, native,
+		dbreaker.WithFailureThreshold(2),
+		dbreaker.WithResetTimeout(timeout),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	breaker := drv.(*dbreaker.Breaker)
+	db, err := sql.Open(wrapper, "trip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	fake.Fail(dbreakertest.Exec, errOutage)
+	for i := 0; i < 2; i++ {
+		if _, err := db.Exec("insert into users values(1)"); err != errOutage {
+			t.Fatalf("expected %v but got: %v", errOutage, err)
+		}
+	}
+	if _, err := db.Exec("insert into users values(1)"); !errors.Is(err, dbreaker.ErrDown) {
+		t.Fatalf("expected %v once tripped but got: %v", dbreaker.ErrDown, err)
+	}
+	if n := fake.Calls(dbreakertest.Exec); n != 2 {
+		t.Fatalf("expected the breaker to stop execs reaching the driver after 2 but got: %d", n)
+	}
+
+	// the half-open probe succeeds once the outage is over
+	fake.Fail(dbreakertest.Exec, nil)
+	time.Sleep(timeout)
+	if _, err := db.Exec("insert into users values(1)"); err != nil {
+		t.Fatal("exec fail:", err)
+	}
+	if state := breaker.State(); state != dbreaker.Closed {
+		t.Fatalf("expected state %v but got: %v", dbreaker.Closed, state)
+	}
+}
+
+func TestBlock(t *testing.T) {
+	const wrapper = "dbreakertest-block"
+	fake, native := dbreakertest.Register()
+	if _, err := dbreaker.NewDriverWithOptions(wrapper, native, dbreaker.WithOperationTimeout(10*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open(wrapper, "block")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.Ping(); err != nil {
+		t.Fatal(err)
+	}
+
+	release := fake.Block(dbreakertest.Query)
+	defer release()
+	if _, err := db.QueryContext(context.Background(), "select 1"); err != context.DeadlineExceeded {
+		t.Fatalf("expected the blocked query to time out but got: %v", err)
+	}
+
+	release()
+	rows, err := db.Query("select 1")
+	if err != nil {
+		t.Fatal("query fail:", err)
+	}
+	rows.Close()
+	if n := fake.Calls(dbreakertest.Query); n != 2 {
+		t.Fatalf("expected 2 queries but got: %d", n)
+	}
+}
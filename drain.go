@@ -0,0 +1,65 @@
+package dbreaker
+
+import (
+	"context"
+)
+
+// Drain stops the breaker from accepting new work while letting the work
+// already under way finish.
+//
+// While draining, new connections and transactions are refused with ErrDown,
+// but connections already open may keep running statements and transactions
+// already begun may commit or roll back. Drain returns once no operations or
+// transactions are in flight, or with the context's error as soon as ctx is
+// done, and the breaker keeps draining until Disable(false) is called.
+//
+// Operations and transactions still in flight when ctx is done are not
+// aborted, they may run to completion or be ended by closing the database.
+func (w *Breaker) Drain(ctx context.Context) error {
+	w.draining.Store(true)
+	w.imu.Lock()
+	idle := w.idle
+	w.imu.Unlock()
+	if idle == nil {
+		return nil
+	}
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// InFlight returns the number of operations and transactions under way.
+//
+// An operation counts from when the breaker lets it through until the inner
+// driver returns, whether or not it fails, and a transaction until it is
+// committed or rolled back.
+func (w *Breaker) InFlight() int {
+	w.imu.Lock()
+	defer w.imu.Unlock()
+	return w.inflight
+}
+
+// enter counts an operation or transaction as in flight
+func (w *Breaker) enter() {
+	w.imu.Lock()
+	defer w.imu.Unlock()
+	w.inflight++
+	if w.idle == nil {
+		w.idle = make(chan struct{})
+	}
+}
+
+// leave counts an operation or transaction as finished,
+// releasing Drain when none are left in flight
+func (w *Breaker) leave() {
+	w.imu.Lock()
+	defer w.imu.Unlock()
+	w.inflight--
+	if w.inflight == 0 && w.idle != nil {
+		close(w.idle)
+		w.idle = nil
+	}
+}
@@ -0,0 +1,154 @@
+package dbreaker
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/paulstuart/dbreaker/dbreakertest"
+)
+
+func TestDrain(t *testing.T) {
+	const wrapper = "wrapper-drain"
+	ctx := context.Background()
+	_, native := newMock()
+	drv, err := NewDriver(wrapper, native)
+	if err != nil {
+		t.Fatal(err)
+	}
+	breaker := drv.(*Breaker)
+
+	db, err := sql.Open(wrapper, "drain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	drained := make(chan error, 1)
+	go func() { drained <- breaker.Drain(ctx) }()
+
+	select {
+	case err := <-drained:
+		t.Fatalf("expected drain to wait for the transaction but got: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// the transaction in flight keeps working, new ones are refused
+	if _, err := tx.ExecContext(ctx, "insert into users values(1)"); err != nil {
+		t.Fatalf("expected the transaction to continue but got: %v", err)
+	}
+	if _, err := db.BeginTx(ctx, nil); !errors.Is(err, ErrDown) {
+		t.Fatalf("expected new transactions to fail with %v but got: %v", ErrDown, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-drained:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected drain to return after commit")
+	}
+
+	breaker.Disable(false)
+	tx, err = db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("expected transactions once re-enabled but got: %v", err)
+	}
+	tx.Rollback()
+}
+
+func TestDrainContext(t *testing.T) {
+	const wrapper = "wrapper-drain-context"
+	ctx := context.Background()
+	_, native := newMock()
+	drv, err := NewDriver(wrapper, native)
+	if err != nil {
+		t.Fatal(err)
+	}
+	breaker := drv.(*Breaker)
+
+	db, err := sql.Open(wrapper, "drain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// a transaction that is never ended
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+
+	timeout, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	drained := make(chan error, 1)
+	go func() { drained <- breaker.Drain(timeout) }()
+	select {
+	case err := <-drained:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected %v but got: %v", context.DeadlineExceeded, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected drain to return once its context timed out")
+	}
+
+	// the transaction is left running
+	if _, err := tx.ExecContext(ctx, "insert into users values(1)"); err != nil {
+		t.Fatalf("expected the transaction to continue but got: %v", err)
+	}
+}
+
+func TestInFlight(t *testing.T) {
+	const wrapper = "wrapper-in-flight"
+	ctx := context.Background()
+	fake, native := dbreakertest.Register()
+	drv, err := NewDriver(wrapper, native)
+	if err != nil {
+		t.Fatal(err)
+	}
+	breaker := drv.(*Breaker)
+	db, err := sql.Open(wrapper, "in-flight")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.PingContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	release := fake.Block(dbreakertest.Exec)
+	done := make(chan error)
+	go func() {
+		_, err := db.ExecContext(ctx, "insert into users values(1)")
+		done <- err
+	}()
+	deadline := time.Now().Add(time.Second)
+	for breaker.InFlight() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected 1 in flight but got: %d", breaker.InFlight())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// a failing operation is no longer counted once it returns
+	fake.Fail(dbreakertest.Exec, errMock)
+	release()
+	if err := <-done; err != errMock {
+		t.Fatalf("expected %v but got: %v", errMock, err)
+	}
+	if n := breaker.InFlight(); n != 0 {
+		t.Fatalf("expected none in flight but got: %d", n)
+	}
+}
@@ -0,0 +1,51 @@
+package dbreaker
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+)
+
+// emptyConn stands in for a connection while the breaker is down,
+// reading no rows and refusing everything else with err
+type emptyConn struct {
+	err error
+}
+
+func (c emptyConn) Prepare(query string) (driver.Stmt, error) {
+	return emptyStmt(c), nil
+}
+
+func (c emptyConn) Close() error { return nil }
+
+func (c emptyConn) Begin() (driver.Tx, error) { return nil, c.err }
+
+func (c emptyConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return nil, c.err
+}
+
+func (c emptyConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return nil, c.err
+}
+
+func (c emptyConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return emptyRows{}, nil
+}
+
+// emptyStmt is a statement prepared on an emptyConn
+type emptyStmt struct {
+	err error
+}
+
+func (s emptyStmt) Close() error  { return nil }
+func (s emptyStmt) NumInput() int { return -1 }
+
+func (s emptyStmt) Exec(args []driver.Value) (driver.Result, error) { return nil, s.err }
+func (s emptyStmt) Query(args []driver.Value) (driver.Rows, error)  { return emptyRows{}, nil }
+
+// emptyRows is a result set without columns or rows
+type emptyRows struct{}
+
+func (emptyRows) Columns() []string              { return nil }
+func (emptyRows) Close() error                   { return nil }
+func (emptyRows) Next(dest []driver.Value) error { return io.EOF }
@@ -0,0 +1,63 @@
+package dbreaker
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+func TestEmptyReadsWhenDown(t *testing.T) {
+	const wrapper = "wrapper-empty-reads"
+	ctx := context.Background()
+	mock, native := newMock()
+	drv, err := NewDriverWithOptions(wrapper, native, WithEmptyReadsWhenDown(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open(wrapper, "empty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.ExecContext(ctx, "insert into users values(1)"); err != nil {
+		t.Fatal(err)
+	}
+
+	drv.Disable(true)
+	empty := func(rows *sql.Rows, err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("expected no error reading but got: %v", err)
+		}
+		defer rows.Close()
+		if rows.Next() {
+			t.Fatal("expected no rows")
+		}
+		if err := rows.Err(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	empty(db.QueryContext(ctx, "select * from users"))
+	stmt, err := db.PrepareContext(ctx, "select * from users where id = ?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	empty(stmt.QueryContext(ctx, 1))
+
+	if _, err := db.ExecContext(ctx, "insert into users values(2)"); !errors.Is(err, ErrDown) {
+		t.Fatalf("expected %v but got: %v", ErrDown, err)
+	}
+	if _, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true}); !errors.Is(err, ErrDown) {
+		t.Fatalf("expected %v but got: %v", ErrDown, err)
+	}
+
+	drv.Disable(false)
+	if _, err := db.ExecContext(ctx, "insert into users values(3)"); err != nil {
+		t.Fatalf("expected writes once enabled but got: %v", err)
+	}
+	if n := len(mock.Execs()); n != 2 {
+		t.Fatalf("expected 2 execs to reach the database but got: %d", n)
+	}
+}
@@ -0,0 +1,108 @@
+package dbreaker
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultEventBuffer is the capacity of the Events channel
+const DefaultEventBuffer = 64
+
+// EventType identifies what an Event is reporting
+type EventType int
+
+const (
+	// EventTrip is sent when the breaker opens
+	EventTrip EventType = iota
+	// EventHalfOpen is sent when the breaker lets probes through
+	EventHalfOpen
+	// EventReset is sent when the breaker closes
+	EventReset
+	// EventBlocked is sent when an operation is refused
+	EventBlocked
+	// EventWouldBlock is sent when an operation would have been refused
+	// but was let through, see WithDryRun
+	EventWouldBlock
+)
+
+// stateEvents maps the state entered to the event reporting it
+var stateEvents = map[CircuitState]EventType{
+	Open:     EventTrip,
+	HalfOpen: EventHalfOpen,
+	Closed:   EventReset,
+}
+
+func (t EventType) String() string {
+	switch t {
+	case EventTrip:
+		return "trip"
+	case EventHalfOpen:
+		return "half-open"
+	case EventReset:
+		return "reset"
+	case EventBlocked:
+		return "blocked"
+	case EventWouldBlock:
+		return "would-block"
+	}
+	return fmt.Sprintf("EventType(%d)", int(t))
+}
+
+// Event reports a change in the breaker or an operation it refused
+type Event struct {
+	Time   time.Time
+	Type   EventType
+	Name   string // name of the breaker, see WithName
+	DSN    string // data source name, for events concerning a single database
+	Op     string // operation refused: open, exec, query or begin
+	Query  string // fingerprint of the statement refused, see WithQueryFingerprinter
+	Err    error  // error returned for the refused operation, or that would have been
+	Reason string // reason given to ForceOpen, while forced open
+	Audit  Audit  // who made the change, if made by DisableWithContext
+}
+
+// Events returns a channel of the breaker's events.
+//
+// The channel is buffered, see WithEventBuffer, and events are dropped
+// rather than waiting for a slow consumer so operations are never stalled.
+// Events are only sent once Events has been called and every call returns
+// the same channel, which is closed by CloseEvents.
+func (w *Breaker) Events() <-chan Event {
+	w.emu.Lock()
+	defer w.emu.Unlock()
+	if w.events == nil {
+		w.events = make(chan Event, w.eventBuf)
+		if w.closed {
+			close(w.events)
+		}
+	}
+	return w.events
+}
+
+// CloseEvents closes the Events channel, no more events are sent after it returns
+func (w *Breaker) CloseEvents() {
+	w.emu.Lock()
+	defer w.emu.Unlock()
+	if w.closed {
+		return
+	}
+	w.closed = true
+	if w.events != nil {
+		close(w.events)
+	}
+}
+
+// emit sends e to the events channel, if there is room
+func (w *Breaker) emit(e Event) {
+	w.emu.RLock()
+	defer w.emu.RUnlock()
+	if w.events == nil || w.closed {
+		return
+	}
+	e.Time = w.clock.Now()
+	e.Name = w.name
+	select {
+	case w.events <- e:
+	default:
+	}
+}
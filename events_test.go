@@ -0,0 +1,147 @@
+package dbreaker
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+func TestEvents(t *testing.T) {
+	const wrapper = "wrapper-events"
+	ctx := context.Background()
+	clk := newFakeClock()
+	_, native := newMock()
+	drv, err := NewDriverWithOptions(wrapper, native, WithClock(clk))
+	if err != nil {
+		t.Fatal(err)
+	}
+	breaker := drv.(*Breaker)
+	events := breaker.Events()
+
+	db, err := sql.Open(wrapper, "events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	breaker.Disable(true)
+	conn.ExecContext(ctx, "insert into users values(1)")
+	breaker.Disable(false)
+	breaker.CloseEvents()
+
+	expect := []Event{
+		{Type: EventTrip},
+		{Type: EventBlocked, DSN: "events", Op: opExec, Query: "insert into users values (?)", Err: ErrDown},
+		{Type: EventReset},
+	}
+	var got []Event
+	for e := range events {
+		got = append(got, e)
+	}
+	if len(got) != len(expect) {
+		t.Fatalf("expected events %v but got: %v", expect, got)
+	}
+	for i, e := range got {
+		expect[i].Time = clk.Now()
+		expect[i].Name = wrapper
+		if !errors.Is(e.Err, expect[i].Err) {
+			t.Fatalf("expected event %d to have error %v but got: %v", i, expect[i].Err, e.Err)
+		}
+		e.Err = expect[i].Err
+		if e != expect[i] {
+			t.Fatalf("expected event %d to be %+v but got: %+v", i, expect[i], e)
+		}
+	}
+
+	// nothing is sent once closed
+	breaker.Disable(true)
+	if _, ok := <-breaker.Events(); ok {
+		t.Fatal("expected the events channel to be closed")
+	}
+}
+
+func TestEventsDropped(t *testing.T) {
+	w := newBreaker("sqlite3", WithEventBuffer(1))
+	events := w.Events()
+
+	// a full buffer must not block the breaker
+	w.Disable(true)
+	w.Disable(false)
+	w.Disable(true)
+
+	if e := <-events; e.Type != EventTrip {
+		t.Fatalf("expected %v but got: %v", EventTrip, e.Type)
+	}
+	select {
+	case e := <-events:
+		t.Fatalf("expected later events to be dropped but got: %+v", e)
+	default:
+	}
+}
+
+func TestDisableWithContext(t *testing.T) {
+	_, native := newMock()
+	breaker := newBreaker(native)
+	events := breaker.Events()
+
+	ctx := context.WithValue(context.Background(), ActorKey, "oncall")
+	ctx = context.WithValue(ctx, CorrelationIDKey, "incident-42")
+	want := Audit{Actor: "oncall", CorrelationID: "incident-42"}
+	breaker.DisableWithContext(ctx, true)
+	breaker.Disable(false)
+	breaker.CloseEvents()
+
+	var got []Event
+	for e := range events {
+		got = append(got, e)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected 2 events but got: %+v", got)
+	}
+	if got[0].Type != EventTrip || got[0].Audit != want {
+		t.Fatalf("expected a trip by %+v but got: %+v", want, got[0])
+	}
+	if got[1].Audit != (Audit{}) {
+		t.Fatalf("expected no audit for a plain Disable but got: %+v", got[1].Audit)
+	}
+	if audit := breaker.Stats().LastAudit; audit != want {
+		t.Fatalf("expected last audit %+v but got: %+v", want, audit)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	const wrapper = "wrapper-dry-run"
+	_, native := newMock()
+	drv, err := NewDriverWithOptions(wrapper, native, WithReadOnly(true), WithDryRun(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	breaker := drv.(*Breaker)
+	events := breaker.Events()
+	db, err := sql.Open(wrapper, "dry-run-dsn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec("insert into t values (1)"); err != nil {
+		t.Fatalf("expected the write to run in dry-run mode but got: %v", err)
+	}
+	breaker.CloseEvents()
+	var got []Event
+	for e := range events {
+		got = append(got, e)
+	}
+	if len(got) != 1 || got[0].Type != EventWouldBlock || got[0].Op != opExec || !errors.Is(got[0].Err, ErrReadOnly) {
+		t.Fatalf("expected a would-block event for the write but got: %+v", got)
+	}
+	if blocked := breaker.Stats().BlockedExecs; blocked != 0 {
+		t.Fatalf("expected no blocked execs but got: %d", blocked)
+	}
+}
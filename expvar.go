@@ -0,0 +1,40 @@
+package dbreaker
+
+import (
+	"expvar"
+	"fmt"
+	"sync"
+)
+
+// pmu serializes publishing so that breakers don't take the same names
+var pmu sync.Mutex
+
+// publish registers the breaker's state and counters with expvar,
+// see WithExpvar
+func (w *Breaker) publish(prefix string) {
+	base := prefix
+	if w.name != "" {
+		base += "." + w.name
+	}
+	vars := map[string]func() any{
+		"state":           func() any { return w.State().String() },
+		"down":            func() any { return w.IsDown() },
+		"reason":          func() any { return w.Reason() },
+		"allowed_opens":   func() any { return w.stats.allowedOpens.Load() },
+		"blocked_opens":   func() any { return w.stats.blockedOpens.Load() },
+		"blocked_queries": func() any { return w.stats.blockedQueries.Load() },
+		"blocked_execs":   func() any { return w.stats.blockedExecs.Load() },
+		"trips":           func() any { return w.stats.trips.Load() },
+		"rows_read":       func() any { return w.stats.rowsRead.Load() },
+	}
+
+	pmu.Lock()
+	defer pmu.Unlock()
+	name := base
+	for i := 2; expvar.Get(name+".state") != nil; i++ {
+		name = fmt.Sprintf("%s#%d", base, i)
+	}
+	for key, fn := range vars {
+		expvar.Publish(name+"."+key, expvar.Func(fn))
+	}
+}
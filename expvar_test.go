@@ -0,0 +1,56 @@
+package dbreaker
+
+import (
+	"database/sql"
+	"errors"
+	"expvar"
+	"testing"
+)
+
+func TestWithExpvar(t *testing.T) {
+	const wrapper = "wrapper-expvar"
+	_, native := newMock()
+	drv, err := NewDriverWithOptions(wrapper, native, WithExpvar("dbreaker"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	breaker := drv.(*Breaker)
+	db, err := sql.Open(wrapper, "expvar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	get := func(name string) string {
+		t.Helper()
+		v := expvar.Get(name)
+		if v == nil {
+			t.Fatalf("expected %s to be published", name)
+		}
+		return v.String()
+	}
+	if state := get("dbreaker.wrapper-expvar.state"); state != `"closed"` {
+		t.Fatalf("expected state closed but got: %s", state)
+	}
+
+	breaker.Disable(true)
+	if _, err := db.Exec("insert into users values(1)"); !errors.Is(err, ErrDown) {
+		t.Fatalf("expected %v but got: %v", ErrDown, err)
+	}
+	if state := get("dbreaker.wrapper-expvar.state"); state != `"open"` {
+		t.Fatalf("expected state open but got: %s", state)
+	}
+	if down := get("dbreaker.wrapper-expvar.down"); down != "true" {
+		t.Fatalf("expected down but got: %s", down)
+	}
+	if blocked := get("dbreaker.wrapper-expvar.blocked_opens"); blocked != "1" {
+		t.Fatalf("expected 1 blocked open but got: %s", blocked)
+	}
+
+	// another breaker of the same name doesn't collide
+	other := newBreaker(native, WithName(wrapper), WithExpvar("dbreaker"))
+	defer other.Close()
+	if state := get("dbreaker.wrapper-expvar#2.state"); state != `"closed"` {
+		t.Fatalf("expected the other breaker closed but got: %s", state)
+	}
+}
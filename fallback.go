@@ -0,0 +1,58 @@
+package dbreaker
+
+import (
+	"database/sql/driver"
+)
+
+// Backend is a database to fall back to while the breaker is down, see WithFallbacks
+type Backend struct {
+	Native string // registered driver name
+	DSN    string // data source name
+
+	// ReadOnly makes writes on the backend fail with ErrReadOnly
+	// rather than the error returned while down
+	ReadOnly bool
+}
+
+// backend is a fallback database and its driver
+type backend struct {
+	Backend
+	drv driver.Driver // looked up on first use, guarded by the Breaker's mu
+}
+
+// openFallback opens a connection to the first fallback database that
+// accepts one in place of one to name, which was refused with err
+func (w *Breaker) openFallback(name string, err error) (driver.Conn, error) {
+	if len(w.fallback) == 0 {
+		if w.empty {
+			return w.wrap(emptyConn{err: err}, name, true), nil
+		}
+		return nil, w.blocked(opOpen, name, err)
+	}
+	for _, b := range w.fallback {
+		var c driver.Conn
+		if c, err = w.openBackend(b); err == nil {
+			conn := w.wrap(c, name, true)
+			conn.src = b
+			return conn, nil
+		}
+	}
+	return nil, err
+}
+
+// openBackend opens a connection to the fallback database b
+func (w *Breaker) openBackend(b *backend) (driver.Conn, error) {
+	var err error
+	w.mu.Lock()
+	drv := b.drv
+	if drv == nil {
+		drv, err = lookup(b.Native)
+		b.drv = drv
+	}
+	w.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	c, err := drv.Open(b.DSN)
+	return checkConn(b.Native, c, err)
+}
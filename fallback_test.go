@@ -0,0 +1,188 @@
+package dbreaker
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync/atomic"
+	"testing"
+)
+
+func TestFallback(t *testing.T) {
+	const driver = "wrapper-fallback"
+	ctx := context.Background()
+	primary, native := newMock()
+	replica, replicaNative := newMock()
+	drv, err := NewDriverWithOptions(driver, native, WithFallback(replicaNative, "replica"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	breaker := drv.(*Breaker)
+	db, err := sql.Open(driver, "primary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	read := func() {
+		t.Helper()
+		rows, err := db.QueryContext(ctx, "select id from users")
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows.Close()
+	}
+	read()
+
+	// reads change over to the replica while writes fail
+	breaker.Disable(true)
+	read()
+	if n := atomic.LoadInt32(&replica.opens); n != 1 {
+		t.Fatalf("expected 1 connection to the replica but got: %d", n)
+	}
+	if _, err := db.ExecContext(ctx, "insert into users values(1)"); !errors.Is(err, ErrDown) {
+		t.Fatalf("expected %v but got: %v", ErrDown, err)
+	}
+	if _, err := db.BeginTx(ctx, nil); !errors.Is(err, ErrDown) {
+		t.Fatalf("expected %v but got: %v", ErrDown, err)
+	}
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx.Rollback()
+	if len(replica.Execs()) != 0 {
+		t.Fatalf("expected no writes to the replica but got: %v", replica.Execs())
+	}
+
+	// and back to the primary once it is up
+	breaker.Disable(false)
+	if _, err := db.ExecContext(ctx, "insert into users values(1)"); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&primary.opens); n != 2 {
+		t.Fatalf("expected 2 connections to the primary but got: %d", n)
+	}
+	if len(primary.Execs()) != 1 {
+		t.Fatalf("expected the write on the primary but got: %v", primary.Execs())
+	}
+}
+
+func TestFallbacks(t *testing.T) {
+	const driver = "wrapper-fallbacks"
+	ctx := context.Background()
+	_, native := newMock()
+	first, firstNative := newMock()
+	second, secondNative := newMock()
+	drv, err := NewDriverWithOptions(driver, native, WithFallbacks([]Backend{
+		{Native: firstNative, DSN: "first"},
+		{Native: secondNative, DSN: "second", ReadOnly: true},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open(driver, "primary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	// the first fallback is down as well, so reads go to the second
+	first.Fail(errMock)
+	drv.Disable(true)
+	rows, err := db.QueryContext(ctx, "select id from users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows.Close()
+	if n := atomic.LoadInt32(&second.opens); n != 1 {
+		t.Fatalf("expected 1 connection to the second fallback but got: %d", n)
+	}
+	if _, err := db.ExecContext(ctx, "insert into users values(1)"); err != ErrReadOnly {
+		t.Fatalf("expected %v from a read-only fallback but got: %v", ErrReadOnly, err)
+	}
+	if len(second.Execs()) != 0 {
+		t.Fatalf("expected no writes to the fallback but got: %v", second.Execs())
+	}
+
+	// with both down the last error is returned
+	second.Fail(errMock)
+	db.SetMaxIdleConns(0)
+	if _, err := db.QueryContext(ctx, "select id from users"); err != errMock {
+		t.Fatalf("expected %v but got: %v", errMock, err)
+	}
+}
+
+func TestStaleConnRetried(t *testing.T) {
+	const wrapper = "wrapper-stale-conn"
+	ctx := context.Background()
+	_, native := newMock()
+	_, replicaNative := newMock()
+	drv, err := NewDriverWithOptions(wrapper, native, WithFallback(replicaNative, "replica"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	breaker := drv.(*Breaker)
+	open := func() *Conn {
+		t.Helper()
+		c, err := breaker.Open("primary")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c.(*Conn)
+	}
+	primary, busy := open(), open()
+	tx, err := busy.BeginTx(ctx, driver.TxOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a connection to the primary is retried on the fallback once down
+	breaker.Disable(true)
+	if _, err := primary.ExecContext(ctx, "insert into users values(1)", nil); err != driver.ErrBadConn {
+		t.Fatalf("expected %v but got: %v", driver.ErrBadConn, err)
+	}
+	if _, err := primary.BeginTx(ctx, driver.TxOptions{}); err != driver.ErrBadConn {
+		t.Fatalf("expected %v but got: %v", driver.ErrBadConn, err)
+	}
+	if _, err := primary.ExecContext(WithBypass(ctx), "insert into users values(1)", nil); err != nil {
+		t.Fatalf("expected a bypassed exec to stay on the primary but got: %v", err)
+	}
+
+	// unlike one in a transaction, which can't move
+	if _, err := busy.ExecContext(ctx, "insert into users values(1)", nil); !errors.Is(err, ErrDown) {
+		t.Fatalf("expected %v in a transaction but got: %v", ErrDown, err)
+	}
+	tx.Rollback()
+
+	// and a connection to the fallback is retried on the primary once up
+	backup := open()
+	breaker.Disable(false)
+	if _, err := backup.QueryContext(ctx, "select id from users", nil); err != driver.ErrBadConn {
+		t.Fatalf("expected %v but got: %v", driver.ErrBadConn, err)
+	}
+}
+
+func TestDownConnNotRetried(t *testing.T) {
+	const wrapper = "wrapper-down-conn"
+	_, native := newMock()
+	drv, err := NewDriver(wrapper, native)
+	if err != nil {
+		t.Fatal(err)
+	}
+	breaker := drv.(*Breaker)
+	c, err := breaker.Open("primary")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// with nowhere else to go, a deliberately downed breaker refuses operations
+	breaker.Disable(true)
+	_, err = c.(*Conn).ExecContext(context.Background(), "insert into users values(1)", nil)
+	if err == driver.ErrBadConn || !errors.Is(err, ErrDown) {
+		t.Fatalf("expected %v but got: %v", ErrDown, err)
+	}
+}
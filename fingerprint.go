@@ -0,0 +1,62 @@
+package dbreaker
+
+import "strings"
+
+// fingerprint normalizes query for reporting, so that statements differing
+// only by their literals, whitespace or comments report the same: string and
+// numeric literals become ?, as do lists of them, and tokens are separated
+// by single spaces. For example "SELECT * FROM users WHERE id IN (1, 2, 3)"
+// becomes "SELECT * FROM users WHERE id IN (?)".
+func fingerprint(query string) string {
+	var toks []string
+	for tok, rest := lenient.token(query); tok != ""; tok, rest = lenient.token(rest) {
+		switch c := tok[0]; {
+		case c == '\'':
+			tok = "?"
+		case c >= '0' && c <= '9':
+			// a decimal point and fraction are part of the number
+			if strings.HasPrefix(rest, ".") {
+				if frac, after := lenient.token(rest[1:]); frac != "" && frac[0] >= '0' && frac[0] <= '9' {
+					rest = after
+				}
+			}
+			tok = "?"
+		}
+		if n := len(toks); tok == "?" && n > 1 && toks[n-1] == "," && toks[n-2] == "?" {
+			// a list of literals becomes a single one
+			toks = toks[:n-1]
+			continue
+		}
+		toks = append(toks, tok)
+	}
+	var b strings.Builder
+	for i, tok := range toks {
+		if i > 0 && !tight(toks[i-1], tok) {
+			b.WriteByte(' ')
+		}
+		b.WriteString(tok)
+	}
+	return b.String()
+}
+
+// tight reports whether tok follows last without a space between them
+func tight(last, tok string) bool {
+	switch {
+	case last == "(" || last == ".":
+		return true
+	case tok == "," || tok == ")" || tok == "." || tok == ";":
+		return true
+	}
+	return false
+}
+
+// fingerprintOf returns the fingerprint query is reported by,
+// see WithQueryFingerprinter
+func (w *Breaker) fingerprintOf(query string) (print string) {
+	if w.fingerprint != nil {
+		if w.guard("query fingerprinter", func() { print = w.fingerprint(query) }) == nil {
+			return print
+		}
+	}
+	return fingerprint(query)
+}
@@ -0,0 +1,68 @@
+package dbreaker
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+)
+
+func TestFingerprint(t *testing.T) {
+	tests := []struct {
+		query string
+		print string
+	}{
+		{"SELECT * FROM users WHERE id=1", "SELECT * FROM users WHERE id = ?"},
+		{"SELECT *\n\tFROM users   WHERE id = 2", "SELECT * FROM users WHERE id = ?"},
+		{"select name from users where name = 'it''s' -- why\n", "select name from users where name = ?"},
+		{"/* hint */ select u.id from users u where u.score > 1.5", "select u.id from users u where u.score > ?"},
+		{"select count(*) from users where id in (1, 2, 3)", "select count (*) from users where id in (?)"},
+		{"insert into t (a, b) values ('x', 2), ('y', 3)", "insert into t (a, b) values (?), (?)"},
+		{`select "col1" from t where x = $1`, `select "col1" from t where x = $1`},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := fingerprint(tt.query); got != tt.print {
+			t.Errorf("fingerprint(%q) = %q, expected %q", tt.query, got, tt.print)
+		}
+	}
+	if a, b := fingerprint("select * from users where id=1"), fingerprint("select * from users where id=2"); a != b {
+		t.Fatalf("expected the same fingerprint but got: %q and %q", a, b)
+	}
+}
+
+func TestWithQueryFingerprinter(t *testing.T) {
+	const wrapper = "wrapper-fingerprinter"
+	ctx := context.Background()
+	_, native := newMock()
+	drv, err := NewDriverWithOptions(wrapper, native, WithQueryFingerprinter(strings.ToUpper))
+	if err != nil {
+		t.Fatal(err)
+	}
+	breaker := drv.(*Breaker)
+	events := breaker.Events()
+	db, err := sql.Open(wrapper, "fingerprinter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	breaker.Disable(true)
+	conn.QueryContext(ctx, "select * from users where id=1")
+	breaker.CloseEvents()
+	for e := range events {
+		if e.Type != EventBlocked {
+			continue
+		}
+		if e.Query != "SELECT * FROM USERS WHERE ID=1" {
+			t.Fatalf("expected the query as fingerprinted but got: %q", e.Query)
+		}
+		return
+	}
+	t.Fatal("expected a blocked event")
+}
@@ -0,0 +1,36 @@
+package dbreaker
+
+// ForceOpen opens the breaker for the given reason, e.g. "deploy in progress",
+// until ForceClose is called. It takes precedence over the automatic circuit
+// breaker, which cannot close the breaker while it is forced open.
+//
+// The reason is reported by Reason, Stats, the events sent for the change
+// and the log, and is set before state change hooks run so they may call
+// Reason to learn why the breaker opened.
+func (w *Breaker) ForceOpen(reason string) {
+	w.forced.Store(&reason)
+	w.notify()
+}
+
+// ForceClose ends ForceOpen and resets the automatic circuit breaker to closed.
+//
+// The breaker stays down if it has been disabled by Disable, DisableName
+// or a scheduled maintenance window.
+func (w *Breaker) ForceClose() {
+	w.forced.Store(nil)
+	w.circuit.reset(w.clock.Now())
+	w.notify()
+}
+
+// Reason returns the reason given to ForceOpen, or "" if the breaker is not forced open
+func (w *Breaker) Reason() string {
+	if reason := w.forced.Load(); reason != nil {
+		return *reason
+	}
+	return ""
+}
+
+// isForced reports whether the breaker has been forced open
+func (w *Breaker) isForced() bool {
+	return w.forced.Load() != nil
+}
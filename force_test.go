@@ -0,0 +1,82 @@
+package dbreaker
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestForceOpen(t *testing.T) {
+	const (
+		driver = "wrapper-force"
+		reason = "deploy in progress"
+		insert = "insert into users values(1)"
+	)
+	clk := newFakeClock()
+	mock, native := newMock()
+	drv, err := NewDriverWithOptions(driver, native,
+		WithClock(clk),
+		WithFailureThreshold(1),
+		WithResetTimeout(time.Minute),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	breaker := drv.(*Breaker)
+	var reasons []string
+	breaker.OnStateChange(func(old, new CircuitState) {
+		reasons = append(reasons, breaker.Reason())
+	})
+	events := breaker.Events()
+	db, err := sql.Open(driver, "force")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	breaker.ForceOpen(reason)
+	if got := breaker.Stats().Reason; got != reason {
+		t.Fatalf("expected stats reason %q but got: %q", reason, got)
+	}
+	if _, err := db.Exec(insert); !errors.Is(err, ErrDown) {
+		t.Fatalf("expected %v but got: %v", ErrDown, err)
+	}
+	breaker.ForceClose()
+	if got := breaker.Stats().Reason; got != "" {
+		t.Fatalf("expected no reason after ForceClose but got: %q", got)
+	}
+	if len(reasons) != 2 || reasons[0] != reason || reasons[1] != "" {
+		t.Fatalf("expected the hook to see reasons [%q \"\"] but got: %q", reason, reasons)
+	}
+	breaker.CloseEvents()
+	var got []Event
+	for e := range events {
+		if e.Type != EventBlocked {
+			got = append(got, e)
+		}
+	}
+	if len(got) != 2 || got[0].Type != EventTrip || got[0].Reason != reason || got[1].Reason != "" {
+		t.Fatalf("expected a trip event with reason %q but got: %+v", reason, got)
+	}
+
+	// once tripped automatically, the reset timeout does not override forcing open
+	mock.Fail(errMock)
+	db.Exec(insert)
+	mock.Fail(nil)
+	breaker.ForceOpen(reason)
+	clk.Advance(time.Minute)
+	if state := breaker.State(); state != Open {
+		t.Fatalf("expected state %v while forced open but got: %v", Open, state)
+	}
+	if _, err := db.Exec(insert); !errors.Is(err, ErrDown) {
+		t.Fatalf("expected %v but got: %v", ErrDown, err)
+	}
+	breaker.ForceClose()
+	if state := breaker.State(); state != Closed {
+		t.Fatalf("expected state %v after ForceClose but got: %v", Closed, state)
+	}
+	if _, err := db.Exec(insert); err != nil {
+		t.Fatal("exec fail:", err)
+	}
+}
@@ -1,3 +1,5 @@
 module github.com/paulstuart/dbreaker
 
-go 1.13
+go 1.21
+
+require github.com/mattn/go-sqlite3 v1.14.52
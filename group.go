@@ -0,0 +1,75 @@
+package dbreaker
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// Group reports on several breakers at once, e.g. for the readiness probe
+// of a service with more than one database
+type Group struct {
+	breakers []*Breaker
+}
+
+// groupStatus is the group as reported by its ServeHTTP
+type groupStatus struct {
+	Ready    bool              `json:"ready"`
+	Breakers map[string]status `json:"breakers"`
+}
+
+// NewGroup returns a Group of the breakers, which should be told apart by
+// giving them names with WithName. Unnamed breakers are known by their
+// index in the group instead.
+func NewGroup(breakers ...*Breaker) *Group {
+	return &Group{breakers: breakers}
+}
+
+// States returns the current state of each breaker in the group by name
+func (g *Group) States() map[string]CircuitState {
+	states := make(map[string]CircuitState, len(g.breakers))
+	for i, w := range g.breakers {
+		states[g.name(i, w)] = w.State()
+	}
+	return states
+}
+
+// Ready reports whether every breaker in the group is closed
+func (g *Group) Ready() bool {
+	for _, w := range g.breakers {
+		if w.State() != Closed {
+			return false
+		}
+	}
+	return true
+}
+
+// ServeHTTP responds to GET with the status of each breaker in the group as
+// Handler does, keyed by name under "breakers", and whether all of them are
+// closed as "ready". The response is 503 Service Unavailable unless ready.
+func (g *Group) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		rw.Header().Set("Allow", "GET, HEAD")
+		http.Error(rw, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	s := groupStatus{Ready: true, Breakers: make(map[string]status, len(g.breakers))}
+	for i, w := range g.breakers {
+		st := w.status()
+		s.Breakers[g.name(i, w)] = st
+		s.Ready = s.Ready && st.State == Closed.String()
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	if !s.Ready {
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(rw).Encode(s)
+}
+
+// name returns the name of w, the i'th breaker of the group
+func (g *Group) name(i int, w *Breaker) string {
+	if name := w.Name(); name != "" {
+		return name
+	}
+	return strconv.Itoa(i)
+}
@@ -0,0 +1,71 @@
+package dbreaker
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGroup(t *testing.T) {
+	_, native := newMock()
+	primary := newBreaker(native, WithName("primary"))
+	reports := newBreaker(native, WithName("reports"))
+	unnamed := newBreaker(native)
+	group := NewGroup(primary, reports, unnamed)
+	srv := httptest.NewServer(group)
+	defer srv.Close()
+
+	// get fetches the group status, expecting code
+	get := func(code int) groupStatus {
+		t.Helper()
+		resp, err := http.Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != code {
+			t.Fatalf("expected status %d but got: %d", code, resp.StatusCode)
+		}
+		var s groupStatus
+		if err := json.NewDecoder(resp.Body).Decode(&s); err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	if s := get(http.StatusOK); !s.Ready || len(s.Breakers) != 3 {
+		t.Fatalf("expected all breakers ready but got: %+v", s)
+	}
+	if !group.Ready() {
+		t.Fatal("expected the group to be ready")
+	}
+
+	reports.Disable(true)
+	s := get(http.StatusServiceUnavailable)
+	if s.Ready {
+		t.Fatalf("expected the group not to be ready but got: %+v", s)
+	}
+	if st := s.Breakers["reports"]; st.State != "open" || !st.Down {
+		t.Fatalf("expected reports to be down but got: %+v", st)
+	}
+	if st := s.Breakers["primary"]; st.State != "closed" {
+		t.Fatalf("expected primary to be closed but got: %+v", st)
+	}
+	if group.Ready() {
+		t.Fatal("expected the group not to be ready")
+	}
+	states := group.States()
+	if len(states) != 3 || states["primary"] != Closed || states["reports"] != Open || states["2"] != Closed {
+		t.Fatalf("expected primary and 2 closed with reports open but got: %v", states)
+	}
+
+	resp, err := http.Post(srv.URL, "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("expected status %d but got: %d", http.StatusMethodNotAllowed, resp.StatusCode)
+	}
+}
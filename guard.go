@@ -0,0 +1,20 @@
+package dbreaker
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// guard calls fn, which runs a callback given by the user, recovering from
+// a panic in it so that it can't crash the caller or leave the breaker half
+// way through an update. The panic is logged and returned as an error.
+func (w *Breaker) guard(hook string, fn func()) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%s panicked: %v", hook, r)
+			w.log(slog.LevelError, "dbreaker recovered from panic", "hook", hook, "panic", r)
+		}
+	}()
+	fn()
+	return nil
+}
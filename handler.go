@@ -0,0 +1,104 @@
+package dbreaker
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// status is the breaker as reported by Handler
+type status struct {
+	State  string      `json:"state"`
+	Down   bool        `json:"down"`
+	Reason string      `json:"reason,omitempty"`
+	Stats  statusStats `json:"stats"`
+}
+
+// statusStats are the breaker's counters as reported by Handler
+type statusStats struct {
+	AllowedOpens   uint64     `json:"allowed_opens"`
+	BlockedOpens   uint64     `json:"blocked_opens"`
+	BlockedQueries uint64     `json:"blocked_queries"`
+	BlockedExecs   uint64     `json:"blocked_execs"`
+	Trips          uint64     `json:"trips"`
+	RowsRead       uint64     `json:"rows_read"`
+	LastTrip       *time.Time `json:"last_trip,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+}
+
+// control is a request to Handler to change the breaker
+type control struct {
+	Down   *bool  `json:"down"`
+	Reason string `json:"reason"`
+}
+
+// Handler returns an http.Handler to inspect and control the breaker,
+// e.g. from an admin mux.
+//
+// GET responds with the breaker's state and stats as JSON. POST or PUT
+// with a body of {"down": true} disables the breaker, or forces it open
+// with ForceOpen if a "reason" is given as well, while {"down": false}
+// enables it again, ending ForceOpen, and both respond as GET does.
+//
+// The handler does no authentication of its own.
+func (w *Breaker) Handler() http.Handler {
+	return http.HandlerFunc(w.serveHTTP)
+}
+
+func (w *Breaker) serveHTTP(rw http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+	case http.MethodPost, http.MethodPut:
+		var req control
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(rw, "invalid request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.Down == nil {
+			http.Error(rw, `invalid request: "down" is required`, http.StatusBadRequest)
+			return
+		}
+		switch {
+		case *req.Down && req.Reason != "":
+			w.ForceOpen(req.Reason)
+		case *req.Down:
+			w.Disable(true)
+		default:
+			if w.isForced() {
+				w.ForceClose()
+			}
+			w.Disable(false)
+		}
+	default:
+		rw.Header().Set("Allow", "GET, HEAD, POST, PUT")
+		http.Error(rw, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(w.status())
+}
+
+// status reports the breaker for Handler
+func (w *Breaker) status() status {
+	stats := w.Stats()
+	s := status{
+		State:  w.State().String(),
+		Down:   w.IsDown(),
+		Reason: stats.Reason,
+		Stats: statusStats{
+			AllowedOpens:   stats.AllowedOpens,
+			BlockedOpens:   stats.BlockedOpens,
+			BlockedQueries: stats.BlockedQueries,
+			BlockedExecs:   stats.BlockedExecs,
+			Trips:          stats.Trips,
+			RowsRead:       stats.RowsRead,
+		},
+	}
+	if !stats.LastTrip.IsZero() {
+		s.Stats.LastTrip = &stats.LastTrip
+	}
+	if stats.LastError != nil {
+		s.Stats.LastError = stats.LastError.Error()
+	}
+	return s
+}
@@ -0,0 +1,79 @@
+package dbreaker
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	_, native := newMock()
+	breaker := newBreaker(native)
+	srv := httptest.NewServer(breaker.Handler())
+	defer srv.Close()
+
+	// send makes a request and decodes the status it responds with
+	send := func(method, body string) status {
+		t.Helper()
+		req, err := http.NewRequest(method, srv.URL, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected status %d but got: %d", http.StatusOK, resp.StatusCode)
+		}
+		var s status
+		if err := json.NewDecoder(resp.Body).Decode(&s); err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	if s := send(http.MethodGet, ""); s.State != "closed" || s.Down {
+		t.Fatalf("expected the breaker to be up but got: %+v", s)
+	}
+	if s := send(http.MethodPost, `{"down": true}`); s.State != "open" || !s.Down || !breaker.IsDown() {
+		t.Fatalf("expected the breaker to be down but got: %+v", s)
+	}
+	breaker.Open("handler")
+	if s := send(http.MethodGet, ""); s.Stats.BlockedOpens != 1 {
+		t.Fatalf("expected 1 blocked open but got: %+v", s.Stats)
+	}
+	if s := send(http.MethodPut, `{"down": false}`); s.State != "closed" || s.Down || breaker.IsDown() {
+		t.Fatalf("expected the breaker to be up but got: %+v", s)
+	}
+
+	// a reason forces it open
+	if s := send(http.MethodPost, `{"down": true, "reason": "migration"}`); s.Reason != "migration" || breaker.Reason() != "migration" {
+		t.Fatalf("expected the breaker to be forced open but got: %+v", s)
+	}
+	if s := send(http.MethodPost, `{"down": false}`); s.Reason != "" || breaker.IsDown() {
+		t.Fatalf("expected the breaker to be up but got: %+v", s)
+	}
+}
+
+func TestHandlerErrors(t *testing.T) {
+	_, native := newMock()
+	handler := newBreaker(native).Handler()
+	for _, tc := range []struct {
+		method, body string
+		code         int
+	}{
+		{http.MethodPost, `not json`, http.StatusBadRequest},
+		{http.MethodPost, `{"reason": "missing down"}`, http.StatusBadRequest},
+		{http.MethodDelete, ``, http.StatusMethodNotAllowed},
+	} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(tc.method, "/", strings.NewReader(tc.body)))
+		if rec.Code != tc.code {
+			t.Fatalf("expected %s %q to respond %d but got: %d", tc.method, tc.body, tc.code, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package dbreaker
+
+import (
+	"sync"
+	"time"
+)
+
+// BlockedOp is an operation refused by the breaker, see RecentBlocked
+type BlockedOp struct {
+	Time  time.Time // when it was refused
+	DSN   string    // data source name it was for
+	Op    string    // operation refused: open, exec, query or begin
+	Query string    // fingerprint of the statement refused, if any
+	Err   error     // error returned for it
+}
+
+// history is a ring buffer of the most recent blocked operations
+type history struct {
+	mu   sync.Mutex
+	ops  []BlockedOp // fixed size, empty if no history is kept
+	next int         // index of the oldest op, overwritten next
+	full bool        // every slot of ops is in use
+}
+
+// add records op, overwriting the oldest op once full
+func (h *history) add(op BlockedOp) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.ops) == 0 {
+		return
+	}
+	h.ops[h.next] = op
+	h.next = (h.next + 1) % len(h.ops)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// list returns the ops recorded, oldest first
+func (h *history) list() []BlockedOp {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.full {
+		return append([]BlockedOp(nil), h.ops[:h.next]...)
+	}
+	return append(append([]BlockedOp(nil), h.ops[h.next:]...), h.ops[:h.next]...)
+}
+
+// RecentBlocked returns the most recent operations the breaker refused,
+// oldest first, up to the number kept by WithBlockedHistory. It returns
+// nil if no history is kept.
+func (w *Breaker) RecentBlocked() []BlockedOp {
+	return w.blocks.list()
+}
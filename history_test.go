@@ -0,0 +1,66 @@
+package dbreaker
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRecentBlocked(t *testing.T) {
+	const wrapper = "wrapper-blocked-history"
+	ctx := context.Background()
+	clk := newFakeClock()
+	_, native := newMock()
+	drv, err := NewDriverWithOptions(wrapper, native, WithClock(clk), WithBlockedHistory(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	breaker := drv.(*Breaker)
+	db, err := sql.Open(wrapper, "history-dsn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if got := breaker.RecentBlocked(); len(got) != 0 {
+		t.Fatalf("expected no blocked operations but got: %+v", got)
+	}
+	breaker.Disable(true)
+	for i := 1; i <= 5; i++ {
+		clk.Advance(time.Second)
+		if _, err := conn.ExecContext(ctx, fmt.Sprintf("insert into t values(%d)", i)); !errors.Is(err, ErrDown) {
+			t.Fatalf("expected %v but got: %v", ErrDown, err)
+		}
+		if got := breaker.RecentBlocked(); len(got) != min(i, 3) {
+			t.Fatalf("expected %d blocked operations but got: %+v", min(i, 3), got)
+		}
+	}
+
+	got := breaker.RecentBlocked()
+	for i, op := range got {
+		when := clk.Now().Add(time.Duration(i-2) * time.Second)
+		if !op.Time.Equal(when) || op.DSN != "history-dsn" || op.Op != opExec || !errors.Is(op.Err, ErrDown) {
+			t.Fatalf("expected exec at %v to be blocked but got: %+v", when, op)
+		}
+		if op.Query != "insert into t values (?)" {
+			t.Fatalf("unexpected query: %q", op.Query)
+		}
+	}
+}
+
+func TestRecentBlockedDisabled(t *testing.T) {
+	_, native := newMock()
+	breaker := newBreaker(native)
+	breaker.blocked(opOpen, "dsn", ErrDown)
+	if got := breaker.RecentBlocked(); got != nil {
+		t.Fatalf("expected no history by default but got: %+v", got)
+	}
+}
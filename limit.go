@@ -0,0 +1,34 @@
+package dbreaker
+
+import (
+	"context"
+)
+
+// reserve takes a slot for an operation when they are limited by
+// WithMaxConcurrent, waiting for one to be free if configured to
+func (w *Breaker) reserve(ctx context.Context) error {
+	if w.slots == nil {
+		return nil
+	}
+	if !w.queue {
+		select {
+		case w.slots <- struct{}{}:
+			return nil
+		default:
+			return ErrOverloaded
+		}
+	}
+	select {
+	case w.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// release frees the slot taken by reserve
+func (w *Breaker) release() {
+	if w.slots != nil {
+		<-w.slots
+	}
+}
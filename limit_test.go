@@ -0,0 +1,165 @@
+package dbreaker
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/paulstuart/dbreaker/dbreakertest"
+)
+
+// blockedExec runs an exec on db that blocks in fake until released
+func blockedExec(t *testing.T, fake *dbreakertest.Driver, db *sql.DB) (release func(), done <-chan error) {
+	t.Helper()
+	calls := fake.Calls(dbreakertest.Exec)
+	release = fake.Block(dbreakertest.Exec)
+	ch := make(chan error, 1)
+	go func() {
+		_, err := db.Exec("insert into users values(1)")
+		ch <- err
+	}()
+	deadline := time.Now().Add(time.Second)
+	for fake.Calls(dbreakertest.Exec) == calls {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the exec to reach the driver")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return release, ch
+}
+
+func TestMaxConcurrent(t *testing.T) {
+	const wrapper = "wrapper-max-concurrent"
+	fake, native := dbreakertest.Register()
+	if _, err := NewDriverWithOptions(wrapper, native, WithMaxConcurrent(1)); err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open(wrapper, "limit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	release, done := blockedExec(t, fake, db)
+	if _, err := db.Exec("insert into users values(2)"); err != ErrOverloaded {
+		t.Fatalf("expected %v over the limit but got: %v", ErrOverloaded, err)
+	}
+	release()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	// the slot is free again
+	if _, err := db.Exec("insert into users values(3)"); err != nil {
+		t.Fatalf("expected the exec once the slot was released but got: %v", err)
+	}
+}
+
+func TestMaxConcurrentWait(t *testing.T) {
+	const wrapper = "wrapper-max-concurrent-wait"
+	ctx := context.Background()
+	fake, native := dbreakertest.Register()
+	drv, err := NewDriverWithOptions(wrapper, native, WithMaxConcurrent(1), WithConcurrencyWait(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open(wrapper, "limit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	release, done := blockedExec(t, fake, db)
+	waited := make(chan error, 1)
+	go func() {
+		rows, err := db.QueryContext(ctx, "select * from users")
+		if err == nil {
+			rows.Close()
+		}
+		waited <- err
+	}()
+	select {
+	case err := <-waited:
+		t.Fatalf("expected the query to wait for a free slot but got: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	release()
+	for _, ch := range []<-chan error{done, waited} {
+		select {
+		case err := <-ch:
+			if err != nil {
+				t.Fatal(err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("expected the operations to complete once released")
+		}
+	}
+	if n := drv.(*Breaker).InFlight(); n != 0 {
+		t.Fatalf("expected none in flight but got: %d", n)
+	}
+}
+
+func TestMaxConcurrentWaitCanceled(t *testing.T) {
+	const wrapper = "wrapper-max-concurrent-cancel"
+	ctx := context.Background()
+	fake, native := dbreakertest.Register()
+	drv, err := NewDriverWithOptions(wrapper, native, WithMaxConcurrent(1), WithConcurrencyWait(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open(wrapper, "limit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	// a connection of its own, so the query waits for a slot rather than to connect
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	release, done := blockedExec(t, fake, db)
+	defer release()
+	cctx, cancel := context.WithCancel(ctx)
+	waited := make(chan error, 1)
+	go func() {
+		rows, err := conn.QueryContext(cctx, "select * from users")
+		if err == nil {
+			rows.Close()
+		}
+		waited <- err
+	}()
+	select {
+	case err := <-waited:
+		t.Fatalf("expected the query to wait for a free slot but got: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	cancel()
+	select {
+	case err := <-waited:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected %v but got: %v", context.Canceled, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the canceled query to stop waiting")
+	}
+	if n := fake.Calls(dbreakertest.Query); n != 0 {
+		t.Fatalf("expected the query not to reach the driver but got %d calls", n)
+	}
+
+	release()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if n := drv.(*Breaker).InFlight(); n != 0 {
+		t.Fatalf("expected none in flight but got: %d", n)
+	}
+	if _, err := conn.ExecContext(ctx, "insert into users values(2)"); err != nil {
+		t.Fatalf("expected the slot to be free again but got: %v", err)
+	}
+}
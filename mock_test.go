@@ -0,0 +1,392 @@
+package dbreaker
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var mockCount int32
+
+// point is a custom argument type only the mock driver knows how to handle
+type point struct {
+	X, Y int
+}
+
+// mockDriver is a minimal in-memory driver used to exercise the wrapper
+type mockDriver struct {
+	mu    sync.Mutex
+	execs []string
+	fail  error // returned by all operations when set
+	reset int32 // number of calls to ResetSession
+	opens int32 // number of successful calls to Open
+	flaky int32 // number of calls to Open left to fail
+	// slow delays ExecContext and the Connect of a mockContextDriver, which
+	// give up when their context is done, and QueryContext, which ignores it
+	slow time.Duration
+	// dsns are the names given to Open
+	dsns []string
+}
+
+// newMock registers a fresh mock driver and returns it with its name
+func newMock() (*mockDriver, string) {
+	name := fmt.Sprintf("mock%d", atomic.AddInt32(&mockCount, 1))
+	drv := &mockDriver{}
+	sql.Register(name, drv)
+	return drv, name
+}
+
+// Fail makes all subsequent operations return err, nil restores normal operation
+func (d *mockDriver) Fail(err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.fail = err
+}
+
+// Slow delays subsequent calls to ExecContext, QueryContext and Connect by d
+func (d *mockDriver) Slow(slow time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.slow = slow
+}
+
+func (d *mockDriver) delay() time.Duration {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.slow
+}
+
+func (d *mockDriver) failure() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.fail
+}
+
+func (d *mockDriver) Open(name string) (driver.Conn, error) {
+	d.mu.Lock()
+	d.dsns = append(d.dsns, name)
+	d.mu.Unlock()
+	if err := d.failure(); err != nil {
+		return nil, err
+	}
+	if atomic.AddInt32(&d.flaky, -1) >= 0 {
+		return nil, errMock
+	}
+	atomic.AddInt32(&d.opens, 1)
+	return &mockConn{d: d}, nil
+}
+
+func (d *mockDriver) exec(query string, args []driver.NamedValue) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.execs = append(d.execs, fmt.Sprint(query, args))
+}
+
+// DSNs returns a copy of every name given to Open
+func (d *mockDriver) DSNs() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.dsns...)
+}
+
+// Execs returns a copy of every statement executed, with its arguments
+func (d *mockDriver) Execs() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.execs...)
+}
+
+type mockConn struct {
+	d *mockDriver
+}
+
+func (c *mockConn) Prepare(query string) (driver.Stmt, error) {
+	return &mockStmt{c: c, query: query}, nil
+}
+
+func (c *mockConn) Close() error { return nil }
+
+func (c *mockConn) Begin() (driver.Tx, error) { return mockTx{}, nil }
+
+// legacyConn is a connection with only the methods every driver.Conn has
+type legacyConn struct {
+	c *mockConn
+}
+
+func (c legacyConn) Prepare(query string) (driver.Stmt, error) { return c.c.Prepare(query) }
+func (c legacyConn) Close() error                              { return nil }
+func (c legacyConn) Begin() (driver.Tx, error)                 { return c.c.Begin() }
+
+// legacyDriver is a mockDriver whose connections are legacyConns
+type legacyDriver struct {
+	*mockDriver
+}
+
+// newLegacyMock registers a fresh mock driver of legacy connections
+func newLegacyMock() (*legacyDriver, string) {
+	name := fmt.Sprintf("mock%d", atomic.AddInt32(&mockCount, 1))
+	drv := &legacyDriver{mockDriver: &mockDriver{}}
+	sql.Register(name, drv)
+	return drv, name
+}
+
+func (d *legacyDriver) Open(name string) (driver.Conn, error) {
+	c, err := d.mockDriver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return legacyConn{c: c.(*mockConn)}, nil
+}
+
+func (c *mockConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if err := c.d.failure(); err != nil {
+		return nil, err
+	}
+	return mockTx{}, nil
+}
+
+func (c *mockConn) ResetSession(ctx context.Context) error {
+	atomic.AddInt32(&c.d.reset, 1)
+	return nil
+}
+
+func (c *mockConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if p, ok := nv.Value.(point); ok {
+		nv.Value = fmt.Sprintf("(%d,%d)", p.X, p.Y)
+		return nil
+	}
+	return driver.ErrSkip
+}
+
+func (c *mockConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if err := c.d.failure(); err != nil {
+		return nil, err
+	}
+	if slow := c.d.delay(); slow > 0 {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(slow):
+		}
+	}
+	c.d.exec(query, args)
+	return driver.RowsAffected(1), nil
+}
+
+func (c *mockConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if err := c.d.failure(); err != nil {
+		return nil, err
+	}
+	time.Sleep(c.d.delay())
+	return &mockRows{}, nil
+}
+
+type mockStmt struct {
+	c     *mockConn
+	query string
+}
+
+func (s *mockStmt) Close() error  { return nil }
+func (s *mockStmt) NumInput() int { return -1 }
+
+func (s *mockStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if err := s.c.d.failure(); err != nil {
+		return nil, err
+	}
+	named := make([]driver.NamedValue, len(args))
+	for i, v := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+	}
+	s.c.d.exec(s.query, named)
+	return driver.RowsAffected(1), nil
+}
+
+func (s *mockStmt) Query(args []driver.Value) (driver.Rows, error) {
+	if err := s.c.d.failure(); err != nil {
+		return nil, err
+	}
+	return &mockRows{}, nil
+}
+
+type mockTx struct{}
+
+func (mockTx) Commit() error   { return nil }
+func (mockTx) Rollback() error { return nil }
+
+// mockRows is an empty result set with a single column
+type mockRows struct{}
+
+func (r *mockRows) Columns() []string              { return []string{"value"} }
+func (r *mockRows) Close() error                   { return nil }
+func (r *mockRows) Next(dest []driver.Value) error { return io.EOF }
+
+func (r *mockRows) ColumnTypeDatabaseTypeName(index int) string { return "TEXT" }
+
+// mockContextDriver is a mockDriver that also implements driver.DriverContext
+type mockContextDriver struct {
+	*mockDriver
+	connectors int32 // number of calls to OpenConnector
+	closed     int32 // number of connectors closed
+}
+
+// newContextMock registers a fresh mock driver that supports OpenConnector
+func newContextMock() (*mockContextDriver, string) {
+	name := fmt.Sprintf("mock%d", atomic.AddInt32(&mockCount, 1))
+	drv := &mockContextDriver{mockDriver: &mockDriver{}}
+	sql.Register(name, drv)
+	return drv, name
+}
+
+func (d *mockContextDriver) OpenConnector(name string) (driver.Connector, error) {
+	atomic.AddInt32(&d.connectors, 1)
+	return mockConnector{d: d, name: name}, nil
+}
+
+type mockConnector struct {
+	d    *mockContextDriver
+	name string
+}
+
+func (c mockConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	if slow := c.d.delay(); slow > 0 {
+		select {
+		case <-time.After(slow):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return c.d.Open(c.name)
+}
+
+func (c mockConnector) Driver() driver.Driver { return c.d }
+
+func (c mockConnector) Close() error {
+	atomic.AddInt32(&c.d.closed, 1)
+	return nil
+}
+
+// nilDriver is a buggy driver that opens neither a connection nor an error
+type nilDriver struct{}
+
+// newNilMock registers a fresh nil driver and returns its name
+func newNilMock() string {
+	name := fmt.Sprintf("mock%d", atomic.AddInt32(&mockCount, 1))
+	sql.Register(name, nilDriver{})
+	return name
+}
+
+func (nilDriver) Open(name string) (driver.Conn, error) {
+	return nil, nil
+}
+
+// waitDriver is a driver whose operations given a context hang until it is
+// done, like calls to an unreachable database. Those without one would hang
+// for good, so they fail with errHung instead to fail the test rather than
+// hang it.
+type waitDriver struct {
+	legacy  bool          // open waitLegacyConns rather than waitConns
+	stop    chan struct{} // closed when the test ends
+	waiting atomic.Int32  // operations hanging
+}
+
+// errHung is returned by operations of a waitDriver that would have hung
+var errHung = errors.New("operation without a context would have hung")
+
+// newWaitMock registers a fresh wait driver and returns it with its name
+func newWaitMock(t *testing.T, legacy bool) (*waitDriver, string) {
+	name := fmt.Sprintf("mock%d", atomic.AddInt32(&mockCount, 1))
+	drv := &waitDriver{legacy: legacy, stop: make(chan struct{})}
+	t.Cleanup(func() { close(drv.stop) })
+	sql.Register(name, drv)
+	return drv, name
+}
+
+// wait hangs until ctx is done or the test ends
+func (d *waitDriver) wait(ctx context.Context) error {
+	d.waiting.Add(1)
+	defer d.waiting.Add(-1)
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-d.stop:
+		return errMock
+	}
+}
+
+func (d *waitDriver) Open(name string) (driver.Conn, error) {
+	if d.legacy {
+		return waitLegacyConn{d: d}, nil
+	}
+	return waitConn{waitLegacyConn{d: d}}, nil
+}
+
+// waitLegacyConn is a connection without context operations of its own
+// besides PrepareContext, which the sql package prepares execs and queries with
+type waitLegacyConn struct {
+	d *waitDriver
+}
+
+func (c waitLegacyConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errHung
+}
+
+func (c waitLegacyConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if query == "prepared" {
+		return waitStmt{d: c.d}, nil
+	}
+	return nil, c.d.wait(ctx)
+}
+
+func (c waitLegacyConn) Close() error { return nil }
+
+func (c waitLegacyConn) Begin() (driver.Tx, error) {
+	return nil, errHung
+}
+
+// waitConn is a waitLegacyConn with context operations
+type waitConn struct {
+	waitLegacyConn
+}
+
+func (c waitConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return nil, c.d.wait(ctx)
+}
+
+func (c waitConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return nil, c.d.wait(ctx)
+}
+
+func (c waitConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return nil, c.d.wait(ctx)
+}
+
+// waitStmt is a prepared statement of a wait driver
+type waitStmt struct {
+	d *waitDriver
+}
+
+func (s waitStmt) Close() error  { return nil }
+func (s waitStmt) NumInput() int { return -1 }
+
+func (s waitStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return nil, errHung
+}
+
+func (s waitStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return nil, errHung
+}
+
+func (s waitStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return nil, s.d.wait(ctx)
+}
+
+func (s waitStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return nil, s.d.wait(ctx)
+}
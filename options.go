@@ -0,0 +1,407 @@
+package dbreaker
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Option configures a Breaker created by NewDriverWithOptions.
+//
+// A panic in a callback given by an option, such as a failure classifier or
+// query interceptor, is recovered and logged. The statement or connection
+// it was called for fails with an error describing the panic, while a
+// panicking failure classifier counts the error toward tripping the breaker.
+type Option func(*Breaker)
+
+// WithName sets the name the breaker is known by in events and logs,
+// to tell breakers apart. NewDriver defaults it to the driver name.
+func WithName(name string) Option {
+	return func(w *Breaker) {
+		w.name = name
+	}
+}
+
+// WithFailureThreshold sets the number of consecutive failures that
+// automatically trips the breaker, see SetAutoTrip
+func WithFailureThreshold(n int) Option {
+	return func(w *Breaker) {
+		w.circuit.cfg.Threshold = n
+	}
+}
+
+// WithResetTimeout sets how long an automatically tripped breaker
+// stays open before letting a probe through, see SetAutoTrip
+func WithResetTimeout(d time.Duration) Option {
+	return func(w *Breaker) {
+		w.circuit.cfg.ResetTimeout = d
+	}
+}
+
+// WithBackoffReset doubles the reset timeout for each trip in a row, up to
+// max, to spare a database that keeps failing soon after it recovers. The
+// timeout starts over once the breaker has stayed closed for max, see
+// AutoTrip.MaxResetTimeout.
+func WithBackoffReset(max time.Duration) Option {
+	return func(w *Breaker) {
+		w.circuit.cfg.MaxResetTimeout = max
+	}
+}
+
+// WithWarmup keeps the breaker from tripping for d after it is created or
+// reset by ForceClose, while transient errors are common, see AutoTrip.Warmup
+func WithWarmup(d time.Duration) Option {
+	return func(w *Breaker) {
+		w.circuit.cfg.Warmup = d
+	}
+}
+
+// WithFailureRate sets the ratio of failed operations over window that
+// automatically trips the breaker, once at least minRequests operations
+// have been made within the window, see SetAutoTrip
+func WithFailureRate(ratio float64, window time.Duration, minRequests int) Option {
+	return func(w *Breaker) {
+		w.circuit.cfg.FailureRate = ratio
+		w.circuit.cfg.Window = window
+		w.circuit.cfg.MinRequests = minRequests
+	}
+}
+
+// WithHalfOpenMaxProbes sets the number of operations let through at once
+// to probe the database while half-open, see SetAutoTrip
+func WithHalfOpenMaxProbes(n int) Option {
+	return func(w *Breaker) {
+		w.circuit.cfg.MaxProbes = n
+	}
+}
+
+// WithHalfOpenSuccessThreshold sets the number of consecutive successful
+// probes needed to close the breaker while half-open, see SetAutoTrip
+func WithHalfOpenSuccessThreshold(n int) Option {
+	return func(w *Breaker) {
+		w.circuit.cfg.Successes = n
+	}
+}
+
+// WithAutoProbe makes the breaker probe the database itself every interval
+// while half-open, instead of waiting for traffic to do so. A probe connects
+// to the data source name most recently used and pings it: success closes
+// the breaker as a successful probe would, failure opens it again for
+// another reset timeout. Probing stops when the breaker is closed.
+func WithAutoProbe(interval time.Duration) Option {
+	return func(w *Breaker) {
+		w.interval = interval
+	}
+}
+
+// WithHealthQuery sets a query, e.g. "SELECT 1", to check the database with
+// in place of pinging it, for HealthCheck and WithAutoProbe
+func WithHealthQuery(query string) Option {
+	return func(w *Breaker) {
+		w.health = query
+	}
+}
+
+// WithExpvar publishes the breaker's state and counters with expvar, for
+// /debug/vars, as prefix.name.state, prefix.name.trips and so on, where name
+// is that of the breaker, left out if it has none. If another breaker has
+// already published under the same prefix and name, "#2" is appended to
+// prefix.name, then "#3" and so on.
+//
+// The vars are state, down, reason, allowed_opens, blocked_opens,
+// blocked_queries, blocked_execs, trips and rows_read. As expvar has no way to
+// remove vars they stay published after the breaker is closed.
+func WithExpvar(prefix string) Option {
+	return func(w *Breaker) {
+		w.vars = prefix
+	}
+}
+
+// WithFailureClassifier sets fn to decide which errors from the inner driver
+// count toward automatically tripping the breaker. Errors fn rejects, such as
+// syntax errors or constraint violations, show the database is responding and
+// count as neither successes nor failures: they don't start the count of
+// failures in a row over, close a half-open breaker or add to the failure
+// rate. The default counts every error.
+//
+// A classifier suited to most network drivers counts broken connections,
+// timeouts and network errors:
+//
+//	func(err error) bool {
+//		var netErr net.Error
+//		return errors.Is(err, driver.ErrBadConn) ||
+//			errors.Is(err, context.DeadlineExceeded) ||
+//			errors.As(err, &netErr)
+//	}
+func WithFailureClassifier(fn func(error) bool) Option {
+	return func(w *Breaker) {
+		w.failing = fn
+	}
+}
+
+// WithOperationTimeout sets the longest an ExecContext or QueryContext may
+// run, by giving the inner driver a context with that deadline unless the
+// caller's is sooner. A query's deadline lasts until its rows are closed.
+// Operations that overrun it count as failures toward tripping the breaker,
+// even if the inner driver ignores the deadline and succeeds.
+func WithOperationTimeout(d time.Duration) Option {
+	return func(w *Breaker) {
+		w.timeout = d
+	}
+}
+
+// WithBlockNewOnly makes a breaker that is down by hand, whether by Disable,
+// DisableName, ForceOpen or a maintenance window, refuse only new
+// connections with ErrDown. Connections already open, idle ones included,
+// keep working until they next go back to the pool, which then discards
+// them as IsValid reports them down, so the pool drains gently rather than
+// failing the work under way. A tripped circuit still refuses operations on every
+// connection, and pooled connections still change over to a fallback
+// database or empty reads, if set, as they are reused.
+func WithBlockNewOnly(on bool) Option {
+	return func(w *Breaker) {
+		w.newOnly = on
+	}
+}
+
+// WithEmptyReadsWhenDown makes reads return no rows rather than ErrDown
+// while the breaker is down, for applications that would rather show empty
+// data than an error. Writes and transactions still return ErrDown, and
+// pooled connections change over to and back from empty reads as the sql
+// package reuses them. A fallback database, if set, takes precedence.
+func WithEmptyReadsWhenDown(empty bool) Option {
+	return func(w *Breaker) {
+		w.empty = empty
+	}
+}
+
+// WithMaxConcurrent limits the operations let through to the inner driver at
+// once to n, to keep a fragile database from being swamped. Connections,
+// statements, transactions being started and pings each count while they
+// run. Operations over the limit return ErrOverloaded, or wait for one to
+// finish with WithConcurrencyWait.
+func WithMaxConcurrent(n int) Option {
+	return func(w *Breaker) {
+		w.slots = nil
+		if n > 0 {
+			w.slots = make(chan struct{}, n)
+		}
+	}
+}
+
+// WithConcurrencyWait makes operations over the limit set by WithMaxConcurrent
+// wait for one to finish, or for their context to be done, rather than
+// return ErrOverloaded
+func WithConcurrencyWait(wait bool) Option {
+	return func(w *Breaker) {
+		w.queue = wait
+	}
+}
+
+// WithDSNRewriter sets fn to change data source names before they are
+// passed to the inner driver, e.g. to add connection parameters. Errors from
+// fn are returned by Open and OpenConnector.
+//
+// The breaker knows each database by its rewritten name, which is the one
+// to pass to DisableName and the one reported by Names and events, so fn
+// should not add credentials that must not be logged.
+func WithDSNRewriter(fn func(dsn string) (string, error)) Option {
+	return func(w *Breaker) {
+		w.rewrite = fn
+	}
+}
+
+// WithQueryFingerprinter sets fn to normalize statements to report them by
+// in blocked events and logs, in place of the default which replaces
+// literals with ? and collapses whitespace and comments, to keep the number
+// of distinct statements reported, e.g. as metric labels, in bounds.
+func WithQueryFingerprinter(fn func(query string) string) Option {
+	return func(w *Breaker) {
+		w.fingerprint = fn
+	}
+}
+
+// WithBlockedHistory keeps the last n operations the breaker refused,
+// for RecentBlocked. Zero, the default, keeps none.
+func WithBlockedHistory(n int) Option {
+	return func(w *Breaker) {
+		w.blocks.ops = make([]BlockedOp, max(n, 0))
+		w.blocks.next, w.blocks.full = 0, false
+	}
+}
+
+// WithOnBlocked sets fn to be called for each operation the breaker refuses,
+// e.g. to count them or trace them, with op one of "open", "exec", "query" or
+// "begin", query the fingerprint of the statement refused, see
+// WithQueryFingerprinter, or "" for operations other than statements, and dsn
+// the data source name. It is called synchronously with no locks held.
+func WithOnBlocked(fn func(op, query, dsn string)) Option {
+	return func(w *Breaker) {
+		w.onBlocked = fn
+	}
+}
+
+// WithControlContext ties the breaker to ctx, so that once ctx is done the
+// breaker opens and stays open, e.g. when a leader election lease is lost.
+// The goroutine watching ctx exits when the breaker is closed.
+func WithControlContext(ctx context.Context) Option {
+	return func(w *Breaker) {
+		w.control = ctx
+	}
+}
+
+// WithHealthProbe calls fn every interval and takes the breaker down while it
+// returns false, e.g. to follow an external monitor. It is first called as
+// the breaker is made, then until the breaker is closed, and an interval of
+// zero or less disables the probe. The breaker is down
+// while either fn or Disable says so, and coming up by one leaves it down
+// if the other still holds it. A panic in fn counts as it returning false.
+func WithHealthProbe(fn func() bool, interval time.Duration) Option {
+	return func(w *Breaker) {
+		w.poll = fn
+		w.polling = interval
+	}
+}
+
+// WithMaxOpenConns sets the maximum number of open connections of the
+// database opened by WrapDB, see sql.DB.SetMaxOpenConns. It has no effect
+// on databases opened otherwise.
+func WithMaxOpenConns(n int) Option {
+	return func(w *Breaker) {
+		w.pool.maxOpen = n
+	}
+}
+
+// WithMaxIdleConns sets the maximum number of idle connections of the
+// database opened by WrapDB, see sql.DB.SetMaxIdleConns, which is kept by
+// CloseIdleConnections. It has no effect on databases opened otherwise.
+func WithMaxIdleConns(n int) Option {
+	return func(w *Breaker) {
+		w.pool.maxIdle = n
+		w.pool.idleSet = true
+	}
+}
+
+// WithConnMaxLifetime sets the maximum time connections of the database
+// opened by WrapDB may be reused, see sql.DB.SetConnMaxLifetime. It has no
+// effect on databases opened otherwise.
+func WithConnMaxLifetime(d time.Duration) Option {
+	return func(w *Breaker) {
+		w.pool.lifetime = d
+	}
+}
+
+// WithFallback sets a database to read from while the breaker is open,
+// given by the name of its registered driver and its data source name,
+// e.g. a replica. New connections are made to it instead of returning
+// ErrDown, while writes and transactions other than read-only ones still
+// return ErrDown. Pooled connections change over to and back from it as
+// the sql package reuses them.
+func WithFallback(native, dsn string) Option {
+	return WithFallbacks([]Backend{{Native: native, DSN: dsn}})
+}
+
+// WithFallbacks sets databases to read from while the breaker is open, as
+// WithFallback does for one. Each new connection is made to the first of
+// them to accept it, in order, and the error from the last is returned if
+// none do. Writes only ever go to the primary database.
+func WithFallbacks(backends []Backend) Option {
+	return func(w *Breaker) {
+		w.fallback = make([]*backend, len(backends))
+		for i, b := range backends {
+			w.fallback[i] = &backend{Backend: b}
+		}
+	}
+}
+
+// WithOpenRetry makes up to attempts tries to connect to the inner driver,
+// waiting backoff between them, before the error is returned. Retries stop
+// early if the context of the connection is done or the breaker is disabled,
+// and the attempts count as a single failure toward tripping the breaker.
+func WithOpenRetry(attempts int, backoff time.Duration) Option {
+	return func(w *Breaker) {
+		w.attempts = attempts
+		w.backoff = backoff
+	}
+}
+
+// WithOpenCooldown makes new connections to a data source name fail fast
+// with ErrDown for d after connecting to it failed, rather than each waiting
+// out the dial timeout of an unreachable database and piling up reconnects.
+// Once d has passed a single connection is let through as a probe, others
+// still failing fast meanwhile: the cooldown ends if it connects and starts
+// over if it fails. Failed connections still count toward tripping the
+// breaker, while errors the failure classifier does not count, or of
+// connections given up on by their context, do not start a cooldown.
+func WithOpenCooldown(d time.Duration) Option {
+	return func(w *Breaker) {
+		w.cooldown = d
+	}
+}
+
+// WithQueryInterceptor sets fn to be called with every statement before it
+// is executed, queried or prepared, e.g. to audit statements or veto them.
+// If fn returns an error the statement is not run and the error is returned.
+// Prepared statements are only passed to fn when they are prepared.
+func WithQueryInterceptor(fn func(ctx context.Context, query string) error) Option {
+	return func(w *Breaker) {
+		w.inspect = fn
+	}
+}
+
+// WithErrDown sets the error returned instead of ErrDown while the breaker is down,
+// see SetDownError
+func WithErrDown(err error) Option {
+	return func(w *Breaker) {
+		w.SetDownError(err)
+	}
+}
+
+// WithReadOnly starts the breaker in read-only mode, see SetReadOnly
+func WithReadOnly(on bool) Option {
+	return func(w *Breaker) {
+		w.readOnly.Store(on)
+	}
+}
+
+// WithDryRun sets whether the read-only mode and blocked categories are only
+// reported rather than enforced. Operations they would refuse are logged and
+// sent as EventWouldBlock events, but run as usual and are not counted as
+// blocked, so the classification of statements can be checked against real
+// traffic before turning them on. Operations refused while the breaker is
+// down are refused as usual.
+func WithDryRun(on bool) Option {
+	return func(w *Breaker) {
+		w.dryRun = on
+	}
+}
+
+// WithStateChangeHook registers fn to be called on state changes, see OnStateChange
+func WithStateChangeHook(fn func(old, new CircuitState)) Option {
+	return func(w *Breaker) {
+		w.hooks = append(w.hooks, fn)
+	}
+}
+
+// WithClock sets the source of time for reset timeouts and scheduled windows
+func WithClock(clk Clock) Option {
+	return func(w *Breaker) {
+		w.clock = clk
+	}
+}
+
+// WithEventBuffer sets the capacity of the Events channel
+func WithEventBuffer(n int) Option {
+	return func(w *Breaker) {
+		w.eventBuf = n
+	}
+}
+
+// WithLogger sets the logger for state changes, at warn level when the
+// breaker opens and info otherwise, and blocked operations, at debug level.
+// The default is not to log.
+func WithLogger(l *slog.Logger) Option {
+	return func(w *Breaker) {
+		w.logger = l
+	}
+}
@@ -0,0 +1,381 @@
+package dbreaker
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOptionsAutoTrip(t *testing.T) {
+	const driver = "wrapper-options-trip"
+	var changes []CircuitState
+	mock, native := newMock()
+	drv, err := NewDriverWithOptions(driver, native,
+		WithFailureThreshold(2),
+		WithResetTimeout(time.Hour),
+		WithStateChangeHook(func(old, new CircuitState) {
+			changes = append(changes, new)
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open(driver, "options")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	mock.Fail(errMock)
+	db.Exec("insert into users values(1)")
+	db.Exec("insert into users values(1)")
+	if state := drv.(*Breaker).State(); state != Open {
+		t.Fatalf("expected state %v but got: %v", Open, state)
+	}
+	if len(changes) != 1 || changes[0] != Open {
+		t.Fatalf("expected hook to see [%v] but got: %v", Open, changes)
+	}
+}
+
+func TestOptionsErrDown(t *testing.T) {
+	const driver = "wrapper-options-err"
+	errMaint := errors.New("down for maintenance")
+	_, native := newMock()
+	drv, err := NewDriverWithOptions(driver, native,
+		WithErrDown(errMaint),
+		WithReadOnly(true),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open(driver, "options")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec("insert into users values(1)"); err != ErrReadOnly {
+		t.Fatalf("expected %v but got: %v", ErrReadOnly, err)
+	}
+	drv.Disable(true)
+	if _, err := db.Query("select * from users"); err != errMaint {
+		t.Fatalf("expected %v but got: %v", errMaint, err)
+	}
+}
+
+// recorder is a slog.Handler that keeps the records it handles
+type recorder struct {
+	mu      sync.Mutex
+	records []slog.Record
+}
+
+func (r *recorder) Enabled(context.Context, slog.Level) bool { return true }
+func (r *recorder) WithAttrs([]slog.Attr) slog.Handler       { return r }
+func (r *recorder) WithGroup(string) slog.Handler            { return r }
+
+func (r *recorder) Handle(_ context.Context, rec slog.Record) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.records = append(r.records, rec)
+	return nil
+}
+
+// lines returns each record as its level, message and attributes
+func (r *recorder) lines() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var lines []string
+	for _, rec := range r.records {
+		line := rec.Level.String() + " " + rec.Message
+		rec.Attrs(func(a slog.Attr) bool {
+			line += " " + a.String()
+			return true
+		})
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestWithLogger(t *testing.T) {
+	const wrapper = "wrapper-logger"
+	rec := &recorder{}
+	_, native := newMock()
+	drv, err := NewDriverWithOptions(wrapper, native, WithLogger(slog.New(rec)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	drv.Disable(true)
+	drv.Open("logger")
+	drv.Disable(false)
+
+	expect := []string{
+		"WARN dbreaker state changed breaker=wrapper-logger from=closed to=open",
+		"DEBUG dbreaker blocked operation breaker=wrapper-logger dsn=logger op=open error=database is down (breaker open)",
+		"INFO dbreaker state changed breaker=wrapper-logger from=open to=closed",
+	}
+	lines := rec.lines()
+	if len(lines) != len(expect) {
+		t.Fatalf("expected log %q but got: %q", expect, lines)
+	}
+	for i := range expect {
+		if lines[i] != expect[i] {
+			t.Fatalf("expected log line %q but got: %q", expect[i], lines[i])
+		}
+	}
+}
+
+func TestWithFailureClassifier(t *testing.T) {
+	const driver = "wrapper-options-classifier"
+	errConstraint := errors.New("UNIQUE constraint failed: users.id")
+	errRefused := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	mock, native := newMock()
+	drv, err := NewDriverWithOptions(driver, native,
+		WithFailureThreshold(2),
+		WithFailureClassifier(func(err error) bool {
+			var netErr net.Error
+			return errors.As(err, &netErr)
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	breaker := drv.(*Breaker)
+	db, err := sql.Open(driver, "classifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.Ping(); err != nil {
+		t.Fatal(err)
+	}
+
+	mock.Fail(errConstraint)
+	for i := 0; i < 3; i++ {
+		if _, err := db.Exec("insert into users values(1)"); err != errConstraint {
+			t.Fatalf("expected %v but got: %v", errConstraint, err)
+		}
+	}
+	if state := breaker.State(); state != Closed {
+		t.Fatalf("expected constraint errors to leave the state %v but got: %v", Closed, state)
+	}
+
+	mock.Fail(errRefused)
+	for i := 0; i < 2; i++ {
+		db.Exec("insert into users values(1)")
+	}
+	if state := breaker.State(); state != Open {
+		t.Fatalf("expected connection errors to trip the breaker but got: %v", state)
+	}
+}
+
+func TestClassifierRejectsNeutral(t *testing.T) {
+	errConstraint := errors.New("UNIQUE constraint failed: users.id")
+	classify := WithFailureClassifier(func(err error) bool { return err != errConstraint })
+	clk := newFakeClock()
+	_, native := newMock()
+	breaker := newBreaker(native, WithClock(clk), classify,
+		WithFailureThreshold(2),
+		WithResetTimeout(time.Minute),
+	)
+
+	// run reports the outcome of a single operation
+	run := func(breaker *Breaker, err error) {
+		t.Helper()
+		probe, perr := breaker.acquire(context.Background(), "")
+		if perr != nil {
+			t.Fatalf("expected the operation to be let through but got: %v", perr)
+		}
+		breaker.done("", probe, err)
+	}
+
+	// a rejected error doesn't start the count of failures in a row over
+	run(breaker, errMock)
+	run(breaker, errConstraint)
+	run(breaker, errMock)
+	if state := breaker.State(); state != Open {
+		t.Fatalf("expected state %v but got: %v", Open, state)
+	}
+
+	// nor does it close the breaker as a successful probe
+	clk.Advance(time.Minute)
+	run(breaker, errConstraint)
+	if state := breaker.State(); state != HalfOpen {
+		t.Fatalf("expected state %v after a rejected error but got: %v", HalfOpen, state)
+	}
+	run(breaker, nil)
+	if state := breaker.State(); state != Closed {
+		t.Fatalf("expected state %v but got: %v", Closed, state)
+	}
+
+	// and it is left out of the failure rate
+	breaker = newBreaker(native, WithClock(clk), classify,
+		WithFailureThreshold(100),
+		WithFailureRate(0.5, time.Minute, 4),
+	)
+	run(breaker, errMock)
+	for i := 0; i < 6; i++ {
+		run(breaker, errConstraint)
+	}
+	for i := 0; i < 3; i++ {
+		run(breaker, errMock)
+	}
+	if state := breaker.State(); state != Open {
+		t.Fatalf("expected state %v but got: %v", Open, state)
+	}
+}
+
+func TestWithQueryInterceptor(t *testing.T) {
+	const driver = "wrapper-options-interceptor"
+	ctx := context.Background()
+	errVeto := errors.New("statement vetoed")
+	var seen []string
+	mock, native := newMock()
+	_, err := NewDriverWithOptions(driver, native,
+		WithQueryInterceptor(func(ctx context.Context, query string) error {
+			seen = append(seen, query)
+			if strings.Contains(strings.ToLower(query), "users") {
+				return errVeto
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open(driver, "interceptor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err := db.ExecContext(ctx, "delete from users"); err != errVeto {
+		t.Fatalf("expected %v but got: %v", errVeto, err)
+	}
+	if _, err := db.QueryContext(ctx, "select * from USERS"); err != errVeto {
+		t.Fatalf("expected %v but got: %v", errVeto, err)
+	}
+	if _, err := db.PrepareContext(ctx, "update users set x = 1"); err != errVeto {
+		t.Fatalf("expected %v preparing but got: %v", errVeto, err)
+	}
+	if _, err := db.ExecContext(ctx, "insert into things values(1)"); err != nil {
+		t.Fatal("exec fail:", err)
+	}
+	if execs := mock.Execs(); len(execs) != 1 {
+		t.Fatalf("expected only the allowed statement to run but got: %q", execs)
+	}
+	if len(seen) != 4 {
+		t.Fatalf("expected the interceptor to see 4 statements but got: %q", seen)
+	}
+}
+
+func TestQueryInterceptorLegacy(t *testing.T) {
+	const driver = "wrapper-interceptor-legacy"
+	ctx := context.Background()
+	var seen []string
+	_, native := newLegacyMock()
+	_, err := NewDriverWithOptions(driver, native,
+		WithQueryInterceptor(func(ctx context.Context, query string) error {
+			seen = append(seen, query)
+			return nil
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open(driver, "interceptor-legacy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// statements the sql package prepares for want of ExecContext
+	// and QueryContext are only screened once
+	if _, err := db.ExecContext(ctx, "insert into things values(1)"); err != nil {
+		t.Fatal("exec fail:", err)
+	}
+	rows, err := db.QueryContext(ctx, "select * from things")
+	if err != nil {
+		t.Fatal("query fail:", err)
+	}
+	rows.Close()
+	if len(seen) != 2 {
+		t.Fatalf("expected the interceptor to see 2 statements but got: %q", seen)
+	}
+}
+
+func TestWithName(t *testing.T) {
+	const wrapper = "wrapper-named"
+	_, native := newMock()
+	drv, err := NewDriverWithOptions(wrapper, native, WithName("orders"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	breaker := drv.(*Breaker)
+	if name := breaker.Name(); name != "orders" {
+		t.Fatalf("expected name orders but got: %q", name)
+	}
+	events := breaker.Events()
+	breaker.Disable(true)
+	if e := <-events; e.Name != "orders" {
+		t.Fatalf("expected the event to name the breaker orders but got: %+v", e)
+	}
+
+	// the driver name is the default
+	drv, err = NewDriver("wrapper-unnamed", native)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := drv.(*Breaker).Name(); name != "wrapper-unnamed" {
+		t.Fatalf("expected name wrapper-unnamed but got: %q", name)
+	}
+}
+
+func TestWithDSNRewriter(t *testing.T) {
+	const wrapper = "wrapper-dsn-rewriter"
+	errEmpty := errors.New("empty data source name")
+	mock, native := newContextMock()
+	drv, err := NewDriverWithOptions(wrapper, native, WithDSNRewriter(func(dsn string) (string, error) {
+		if dsn == "" {
+			return "", errEmpty
+		}
+		return dsn + "?application_name=orders", nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	breaker := drv.(*Breaker)
+	const rewritten = "orders?application_name=orders"
+
+	db, err := sql.Open(wrapper, "orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := breaker.Open("orders"); err != nil {
+		t.Fatal(err)
+	}
+	expect := []string{rewritten, rewritten}
+	if dsns := mock.DSNs(); !reflect.DeepEqual(dsns, expect) {
+		t.Fatalf("expected the driver to get %q but got: %q", expect, dsns)
+	}
+	if names := breaker.Names(); !reflect.DeepEqual(names, []string{rewritten}) {
+		t.Fatalf("expected connectors cached by the rewritten name but got: %q", names)
+	}
+
+	if _, err := breaker.Open(""); err != errEmpty {
+		t.Fatalf("expected %v but got: %v", errEmpty, err)
+	}
+	if _, err := breaker.OpenConnector(""); err != errEmpty {
+		t.Fatalf("expected %v but got: %v", errEmpty, err)
+	}
+}
@@ -0,0 +1,50 @@
+package dbreaker
+
+import (
+	"database/sql"
+	"time"
+)
+
+// defaultMaxIdle is the sql package's default number of idle connections
+const defaultMaxIdle = 2
+
+// poolConfig is the pool configuration of databases opened by WrapDB
+type poolConfig struct {
+	maxOpen  int           // see sql.DB.SetMaxOpenConns
+	maxIdle  int           // see sql.DB.SetMaxIdleConns, if idleSet
+	idleSet  bool          // maxIdle has been set
+	lifetime time.Duration // see sql.DB.SetConnMaxLifetime
+}
+
+// apply configures the pool of db
+func (p poolConfig) apply(db *sql.DB) {
+	db.SetMaxOpenConns(p.maxOpen)
+	db.SetMaxIdleConns(p.idle())
+	db.SetConnMaxLifetime(p.lifetime)
+}
+
+// idle returns the number of idle connections to keep
+func (p poolConfig) idle() int {
+	if p.idleSet {
+		return p.maxIdle
+	}
+	return defaultMaxIdle
+}
+
+// CloseIdleConnections closes the idle connections in the pool of the
+// database opened by WrapDB, e.g. once the breaker is disabled, rather than
+// waiting for the sql package to discard them as it tries to reuse them.
+//
+// Connections in use are not closed, they are discarded when returned to
+// the pool while the breaker is down. Databases opened with sql.Open or
+// sql.OpenDB are not known to the breaker, call their SetMaxIdleConns
+// to the same effect.
+func (w *Breaker) CloseIdleConnections() {
+	w.mu.RLock()
+	dbs := w.dbs
+	w.mu.RUnlock()
+	for _, db := range dbs {
+		db.SetMaxIdleConns(0)
+		db.SetMaxIdleConns(w.pool.idle())
+	}
+}
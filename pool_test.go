@@ -0,0 +1,115 @@
+package dbreaker
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCloseIdleConnections(t *testing.T) {
+	ctx := context.Background()
+	_, native := newMock()
+	db, breaker, err := WrapDB(native, "idle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// open two connections at once so both are left idle
+	first, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first.Close()
+	second.Close()
+	if n := db.Stats().Idle; n != 2 {
+		t.Fatalf("expected 2 idle connections but got: %d", n)
+	}
+
+	breaker.Disable(true)
+	breaker.(*Breaker).CloseIdleConnections()
+	if n := db.Stats().OpenConnections; n != 0 {
+		t.Fatalf("expected idle connections to be closed but got: %d open", n)
+	}
+	if err := db.PingContext(ctx); !errors.Is(err, ErrDown) {
+		t.Fatalf("expected %v but got: %v", ErrDown, err)
+	}
+
+	// the pool keeps idle connections again once enabled
+	breaker.Disable(false)
+	if err := db.PingContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := db.Stats().Idle; n != 1 {
+		t.Fatalf("expected 1 idle connection but got: %d", n)
+	}
+}
+
+func TestWrapDBPoolOptions(t *testing.T) {
+	ctx := context.Background()
+	_, native := newMock()
+	db, breaker, err := WrapDB(native, "pool-options",
+		WithMaxOpenConns(3),
+		WithMaxIdleConns(1),
+		WithConnMaxLifetime(10*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if n := db.Stats().MaxOpenConnections; n != 3 {
+		t.Fatalf("expected at most 3 open connections but got: %d", n)
+	}
+
+	// only one of the connections is kept idle
+	var conns []*sql.Conn
+	for i := 0; i < 3; i++ {
+		c, err := db.Conn(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conns = append(conns, c)
+	}
+	for _, c := range conns {
+		c.Close()
+	}
+	stats := db.Stats()
+	if stats.Idle != 1 || stats.MaxIdleClosed != 2 {
+		t.Fatalf("expected 1 idle connection with 2 closed but got: %+v", stats)
+	}
+
+	// which CloseIdleConnections keeps to
+	breaker.(*Breaker).CloseIdleConnections()
+	if n := db.Stats().Idle; n != 0 {
+		t.Fatalf("expected no idle connections but got: %d", n)
+	}
+	conns = conns[:0]
+	for i := 0; i < 2; i++ {
+		c, err := db.Conn(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conns = append(conns, c)
+	}
+	for _, c := range conns {
+		c.Close()
+	}
+	if n := db.Stats().Idle; n != 1 {
+		t.Fatalf("expected 1 idle connection but got: %d", n)
+	}
+
+	// and expires once it has lived too long
+	time.Sleep(20 * time.Millisecond)
+	if err := db.PingContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := db.Stats().MaxLifetimeClosed; n == 0 {
+		t.Fatal("expected connections to be closed for their lifetime")
+	}
+}
@@ -0,0 +1,117 @@
+package dbreaker
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"time"
+)
+
+// autoProbe probes the database every interval while the circuit is half-open,
+// until the breaker is closed
+func (w *Breaker) autoProbe(interval time.Duration) {
+	defer w.watchers.Done()
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-w.quit
+		cancel()
+	}()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-w.clock.After(interval):
+		}
+		if w.state() == HalfOpen {
+			// the probe's outcome runs the state change hooks
+			w.watchers.aside(func() { w.probe(ctx) })
+		}
+	}
+}
+
+// probe checks the data source name last connected to,
+// recording the outcome as that of a half-open probe
+func (w *Breaker) probe(ctx context.Context) {
+	if name := w.dsn.Load(); name != nil {
+		w.check(ctx, *name)
+	}
+}
+
+// HealthCheck checks the database most recently connected to with a new
+// connection, by running the query set by WithHealthQuery or else pinging
+// it if the inner driver supports it, and returns the result.
+//
+// The check counts as an operation toward tripping the breaker, and it is
+// refused with the error returned while down, without connecting, if the
+// circuit is open or the database is disabled, as Open would be.
+func (w *Breaker) HealthCheck(ctx context.Context) error {
+	name := w.dsn.Load()
+	if name == nil {
+		return fmt.Errorf("no database to check, none has been opened")
+	}
+	return w.check(ctx, *name)
+}
+
+// check pings name as an operation let through by the circuit
+func (w *Breaker) check(ctx context.Context, name string) error {
+	if w.unavailable(name) {
+		return w.errDown(name)
+	}
+	probe, err := w.acquire(ctx, name)
+	if err != nil {
+		return err
+	}
+	err = w.ping(ctx, name)
+	w.done(name, probe, w.outcome(ctx, ctx, err))
+	return err
+}
+
+// ping connects to name with the inner driver and runs the health query,
+// or pings it if there is none and the connection supports it
+func (w *Breaker) ping(ctx context.Context, name string) error {
+	w.mu.Lock()
+	drv, err := w.inner()
+	inner := w.conns[name]
+	w.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	var c driver.Conn
+	if inner != nil {
+		c, err = inner.Connect(ctx)
+	} else {
+		c, err = drv.Open(name)
+	}
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if w.health != "" {
+		return runQuery(ctx, c, w.health)
+	}
+	if p, ok := c.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+// runQuery runs query on c, discarding its rows
+func runQuery(ctx context.Context, c driver.Conn, query string) error {
+	var rows driver.Rows
+	err := driver.ErrSkip
+	if q, ok := c.(driver.QueryerContext); ok {
+		rows, err = q.QueryContext(ctx, query, nil)
+	}
+	if err == driver.ErrSkip {
+		var s driver.Stmt
+		if s, err = c.Prepare(query); err != nil {
+			return err
+		}
+		defer s.Close()
+		rows, err = s.Query(nil)
+	}
+	if err != nil {
+		return err
+	}
+	return rows.Close()
+}
@@ -0,0 +1,129 @@
+package dbreaker
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/paulstuart/dbreaker/dbreakertest"
+)
+
+func TestAutoProbe(t *testing.T) {
+	const wrapper = "wrapper-auto-probe"
+	clk := newFakeClock()
+	mock, native := newMock()
+	drv, err := NewDriverWithOptions(wrapper, native,
+		WithClock(clk),
+		WithFailureThreshold(1),
+		WithResetTimeout(time.Minute),
+		WithAutoProbe(10*time.Second),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	breaker := drv.(*Breaker)
+	defer breaker.Close()
+	db, err := sql.Open(wrapper, "probe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	mock.Fail(errMock)
+	db.Exec("insert into users values(1)")
+	if state := breaker.State(); state != Open {
+		t.Fatalf("expected state %v but got: %v", Open, state)
+	}
+
+	// a failed probe keeps it open, waiting for the next probe to be due
+	clk.BlockUntil(1)
+	clk.Advance(time.Minute)
+	clk.BlockUntil(1)
+	if state := breaker.State(); state != Open {
+		t.Fatalf("expected state %v after a failed probe but got: %v", Open, state)
+	}
+
+	// a successful probe closes it without any traffic
+	mock.Fail(nil)
+	clk.Advance(time.Minute)
+	clk.BlockUntil(1)
+	if state := breaker.State(); state != Closed {
+		t.Fatalf("expected state %v after a successful probe but got: %v", Closed, state)
+	}
+	if opens := atomic.LoadInt32(&mock.opens); opens != 1 {
+		t.Fatalf("expected the probe to open 1 connection but got: %d", opens)
+	}
+}
+
+func TestHealthCheck(t *testing.T) {
+	const wrapper = "wrapper-health-check"
+	clk := newFakeClock()
+	fake, native := dbreakertest.Register()
+	drv, err := NewDriverWithOptions(wrapper, native,
+		WithClock(clk),
+		WithFailureThreshold(1),
+		WithResetTimeout(time.Minute),
+		WithHealthQuery("SELECT 1"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	breaker := drv.(*Breaker)
+	if err := breaker.HealthCheck(context.Background()); err == nil {
+		t.Fatal("expected an error checking before any connection")
+	}
+	db, err := sql.Open(wrapper, "health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.Ping(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a failing health query counts toward tripping the breaker
+	fake.Fail(dbreakertest.Query, errMock)
+	if err := breaker.HealthCheck(context.Background()); err != errMock {
+		t.Fatalf("expected error %v but got: %v", errMock, err)
+	}
+	if state := breaker.State(); state != Open {
+		t.Fatalf("expected state %v but got: %v", Open, state)
+	}
+	if err := breaker.HealthCheck(context.Background()); !errors.Is(err, ErrDown) {
+		t.Fatalf("expected error %v while open but got: %v", ErrDown, err)
+	}
+	if calls := fake.Calls(dbreakertest.Query); calls != 1 {
+		t.Fatalf("expected 1 health query but got: %d", calls)
+	}
+
+	// a passing health query closes it again as a probe
+	fake.Fail(dbreakertest.Query, nil)
+	clk.Advance(time.Minute)
+	if err := breaker.HealthCheck(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if state := breaker.State(); state != Closed {
+		t.Fatalf("expected state %v but got: %v", Closed, state)
+	}
+	if calls := fake.Calls(dbreakertest.Query); calls != 2 {
+		t.Fatalf("expected 2 health queries but got: %d", calls)
+	}
+
+	// nor does it reach a database disabled by hand
+	opens := fake.Calls(dbreakertest.Open)
+	breaker.Disable(true)
+	if err := breaker.HealthCheck(context.Background()); !errors.Is(err, ErrDown) {
+		t.Fatalf("expected error %v while disabled but got: %v", ErrDown, err)
+	}
+	breaker.Disable(false)
+	breaker.DisableName("health", true)
+	if err := breaker.HealthCheck(context.Background()); !errors.Is(err, ErrDown) {
+		t.Fatalf("expected error %v with the name disabled but got: %v", ErrDown, err)
+	}
+	if n := fake.Calls(dbreakertest.Open) - opens; n != 0 {
+		t.Fatalf("expected no connections while disabled but got: %d", n)
+	}
+}
@@ -0,0 +1,77 @@
+package dbreaker
+
+import (
+	"time"
+)
+
+// DefaultRateWindow is the period the failure rate is measured over
+// when AutoTrip.Window is not set
+const DefaultRateWindow = 10 * time.Second
+
+// rateBuckets is the number of buckets the failure rate window is divided into
+const rateBuckets = 10
+
+// bucket counts the outcomes of operations over one slice of the window
+type bucket struct {
+	start     time.Time
+	successes int
+	failures  int
+}
+
+// ring tracks the outcomes of operations over a rolling window,
+// as a ring buffer of buckets that are reused as time moves on
+type ring struct {
+	buckets [rateBuckets]bucket
+}
+
+// add records an outcome at now
+func (r *ring) add(now time.Time, window time.Duration, failed bool) {
+	width := int64(window / rateBuckets)
+	if width <= 0 {
+		width = 1
+	}
+	slot := now.UnixNano() / width
+	start := time.Unix(0, slot*width)
+	// slot is negative before 1970, as for the zero time
+	b := &r.buckets[(slot%rateBuckets+rateBuckets)%rateBuckets]
+	if !b.start.Equal(start) {
+		*b = bucket{start: start}
+	}
+	if failed {
+		b.failures++
+	} else {
+		b.successes++
+	}
+}
+
+// totals returns the number of operations and failures within window of now
+func (r *ring) totals(now time.Time, window time.Duration) (total, failures int) {
+	for _, b := range r.buckets {
+		if b.start.IsZero() || now.Sub(b.start) >= window {
+			continue
+		}
+		total += b.successes + b.failures
+		failures += b.failures
+	}
+	return total, failures
+}
+
+// exceeded reports whether the failure rate over the window is above cfg.FailureRate
+func (r *ring) exceeded(now time.Time, cfg AutoTrip) bool {
+	if cfg.FailureRate <= 0 {
+		return false
+	}
+	total, failures := r.totals(now, cfg.window())
+	if total == 0 || total < cfg.MinRequests {
+		return false
+	}
+	return float64(failures)/float64(total) > cfg.FailureRate
+}
+
+// window returns the period the failure rate is measured over
+func (cfg AutoTrip) window() time.Duration {
+	if cfg.Window <= 0 {
+		return DefaultRateWindow
+	}
+	return cfg.Window
+}
@@ -0,0 +1,100 @@
+package dbreaker
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestFailureRate(t *testing.T) {
+	const window = 10 * time.Second
+	clk := newFakeClock()
+	_, native := newMock()
+	breaker := newBreaker(native, WithClock(clk), WithFailureRate(0.5, window, 10))
+
+	// feed runs n operations half a second apart, failing every fail'th one
+	feed := func(n, fail int) {
+		t.Helper()
+		for i := 1; i <= n; i++ {
+			probe, err := breaker.acquire(context.Background(), "")
+			if err != nil {
+				t.Fatalf("operation %d: %v", i, err)
+			}
+			var result error
+			if fail > 0 && i%fail == 0 {
+				result = errMock
+			}
+			breaker.done("", probe, result)
+			clk.Advance(time.Second / 2)
+		}
+	}
+
+	// too few operations to judge the rate
+	feed(4, 1)
+	if state := breaker.State(); state != Closed {
+		t.Fatalf("expected state %v below the minimum requests but got: %v", Closed, state)
+	}
+
+	// failures at the threshold ratio do not trip
+	clk.Advance(window)
+	feed(10, 2)
+	if state := breaker.State(); state != Closed {
+		t.Fatalf("expected state %v at the threshold ratio but got: %v", Closed, state)
+	}
+
+	// failures age out of the window
+	clk.Advance(window)
+	feed(8, 0)
+	feed(4, 1)
+	if state := breaker.State(); state != Closed {
+		t.Fatalf("expected state %v with old failures aged out but got: %v", Closed, state)
+	}
+
+	// intermittent failures over the ratio trip, with no consecutive run
+	clk.Advance(window)
+	feed(10, 2)
+	feed(1, 1)
+	if state := breaker.State(); state != Open {
+		t.Fatalf("expected state %v over the threshold ratio but got: %v", Open, state)
+	}
+}
+
+func TestRingTotals(t *testing.T) {
+	const window = 10 * time.Second
+	now := newFakeClock().Now()
+	var r ring
+	for i := 0; i < 20; i++ {
+		r.add(now.Add(time.Duration(i)*time.Second), window, i%4 == 0)
+	}
+	// only the last 10 seconds remain: 10 through 19
+	total, failures := r.totals(now.Add(19*time.Second), window)
+	if total != 10 || failures != 2 {
+		t.Fatalf("expected 10 operations with 2 failures but got: %d with %d", total, failures)
+	}
+}
+
+func TestFailureRateZeroTime(t *testing.T) {
+	// an unset clock is stuck at the zero time, long before 1970
+	_, native := newMock()
+	breaker := newBreaker(native, WithClock(&fakeClock{}), WithFailureRate(0.5, time.Minute, 4))
+	for i := 0; i < 4; i++ {
+		probe, err := breaker.acquire(context.Background(), "")
+		if err != nil {
+			t.Fatalf("operation %d: %v", i, err)
+		}
+		breaker.done("", probe, errMock)
+	}
+	if state := breaker.State(); state != Open {
+		t.Fatalf("expected state %v but got: %v", Open, state)
+	}
+
+	var r ring
+	before := time.Date(1969, 12, 31, 23, 59, 0, 0, time.UTC)
+	for i := 0; i < 20; i++ {
+		r.add(before.Add(time.Duration(i)*time.Second), 10*time.Second, i%4 == 0)
+	}
+	total, failures := r.totals(before.Add(19*time.Second), 10*time.Second)
+	if total != 10 || failures != 2 {
+		t.Fatalf("expected 10 operations with 2 failures but got: %d with %d", total, failures)
+	}
+}
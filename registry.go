@@ -0,0 +1,34 @@
+package dbreaker
+
+import (
+	"database/sql/driver"
+	"sync"
+)
+
+// registry binds the names registered by NewDriver to their current Breaker,
+// as the sql package does not allow a name to be registered twice
+var (
+	regMu    sync.Mutex
+	registry = make(map[string]*Breaker)
+)
+
+// proxy is the driver registered with the sql package under a name,
+// routing to the Breaker currently bound to that name
+type proxy string
+
+// breaker returns the Breaker bound to the name
+func (p proxy) breaker() *Breaker {
+	regMu.Lock()
+	defer regMu.Unlock()
+	return registry[string(p)]
+}
+
+// Open satisfies the sql.Driver interface
+func (p proxy) Open(name string) (driver.Conn, error) {
+	return p.breaker().Open(name)
+}
+
+// OpenConnector satisfies the driver.DriverContext interface
+func (p proxy) OpenConnector(name string) (driver.Connector, error) {
+	return p.breaker().OpenConnector(name)
+}
@@ -0,0 +1,24 @@
+package dbreaker
+
+import (
+	"context"
+	"database/sql/driver"
+)
+
+// retry calls dial to connect to name, trying again after failures
+// for as long as WithOpenRetry allows
+func (w *Breaker) retry(ctx context.Context, name string, dial func() (driver.Conn, error)) (driver.Conn, error) {
+	c, err := dial()
+	for i := 1; err != nil && i < w.attempts; i++ {
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-w.clock.After(w.backoff):
+		}
+		if w.IsNameDown(name) {
+			return nil, err
+		}
+		c, err = dial()
+	}
+	return c, err
+}
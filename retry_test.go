@@ -0,0 +1,70 @@
+package dbreaker
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestOpenRetry(t *testing.T) {
+	mock, native := newMock()
+	breaker := newBreaker(native, WithOpenRetry(3, time.Millisecond), WithFailureThreshold(1))
+	atomic.StoreInt32(&mock.flaky, 2)
+	c, err := breaker.Open("retry")
+	if err != nil {
+		t.Fatalf("expected the third attempt to succeed but got: %v", err)
+	}
+	c.Close()
+	if state := breaker.State(); state != Closed {
+		t.Fatalf("expected retried failures not to trip the breaker but got: %v", state)
+	}
+
+	// giving up after the last attempt
+	atomic.StoreInt32(&mock.flaky, 3)
+	if _, err := breaker.Open("retry"); err != errMock {
+		t.Fatalf("expected %v but got: %v", errMock, err)
+	}
+	if n := atomic.LoadInt32(&mock.opens); n != 1 {
+		t.Fatalf("expected 1 successful open but got: %d", n)
+	}
+}
+
+func TestOpenRetryContext(t *testing.T) {
+	mock, native := newMock()
+	breaker := newBreaker(native, WithOpenRetry(5, time.Hour))
+	connector, err := breaker.OpenConnector("retry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	atomic.StoreInt32(&mock.flaky, 5)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := connector.Connect(ctx); err != errMock {
+		t.Fatalf("expected %v once the context is done but got: %v", errMock, err)
+	}
+	if n := atomic.LoadInt32(&mock.flaky); n != 4 {
+		t.Fatalf("expected a single attempt before the deadline but got: %d", 5-n)
+	}
+}
+
+func TestOpenRetryDown(t *testing.T) {
+	clk := newFakeClock()
+	mock, native := newMock()
+	breaker := newBreaker(native, WithClock(clk), WithOpenRetry(5, time.Second))
+	atomic.StoreInt32(&mock.flaky, 5)
+	opened := make(chan error, 1)
+	go func() {
+		_, err := breaker.Open("retry")
+		opened <- err
+	}()
+	clk.BlockUntil(1)
+	breaker.Disable(true)
+	clk.Advance(time.Second)
+	if err := <-opened; err != errMock {
+		t.Fatalf("expected %v but got: %v", errMock, err)
+	}
+	if n := atomic.LoadInt32(&mock.flaky); n != 4 {
+		t.Fatalf("expected retries to stop once disabled but got %d attempts", 5-n)
+	}
+}
@@ -0,0 +1,119 @@
+package dbreaker
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+	"reflect"
+)
+
+// rows wraps the rows of the inner driver, forwarding the optional
+// interfaces it implements and falling back to the sql package's defaults
+type rows struct {
+	r      driver.Rows
+	c      *Conn  // connection the rows were read from
+	cancel func() // called once the rows are closed, if set
+	bypass bool   // read on while down, see WithBypass
+	gen    uint64 // generation of the breaker when last checked
+	ended  bool   // the connection was gated when last checked
+}
+
+// bind wraps the rows returned by a query on the connection,
+// tying the context bounding the query, if any, to them
+func (c *Conn) bind(ctx context.Context, r driver.Rows, err error, cancel context.CancelFunc) (driver.Rows, error) {
+	if err != nil {
+		if cancel != nil {
+			cancel()
+		}
+		return nil, err
+	}
+	rs := &rows{r: r, c: c, cancel: cancel, bypass: bypassed(ctx)}
+	rs.check(c.w.gen.Load())
+	return rs, nil
+}
+
+// check records whether the connection is gated as of generation gen
+func (r *rows) check(gen uint64) {
+	r.gen = gen
+	r.ended = !r.bypass && r.c.gated()
+}
+
+func (r *rows) Columns() []string {
+	return r.r.Columns()
+}
+
+func (r *rows) Close() error {
+	if r.cancel != nil {
+		defer r.cancel()
+	}
+	return r.r.Close()
+}
+
+// Next reads the next row, ending the rows early with io.EOF once the
+// breaker is down so that iteration stops cleanly, unless bypassed.
+//
+// Rather than taking the breaker's locks for every row, the connection
+// is only checked again once the breaker's generation has moved on.
+func (r *rows) Next(dest []driver.Value) error {
+	if gen := r.c.w.gen.Load(); gen != r.gen {
+		r.check(gen)
+	}
+	if r.ended {
+		return io.EOF
+	}
+	err := r.r.Next(dest)
+	if err == nil {
+		r.c.w.stats.rowsRead.Add(1)
+		r.c.tally.rowsRead.Add(1)
+	}
+	return err
+}
+
+func (r *rows) HasNextResultSet() bool {
+	if next, ok := r.r.(driver.RowsNextResultSet); ok {
+		return next.HasNextResultSet()
+	}
+	return false
+}
+
+func (r *rows) NextResultSet() error {
+	if next, ok := r.r.(driver.RowsNextResultSet); ok {
+		return next.NextResultSet()
+	}
+	return io.EOF
+}
+
+func (r *rows) ColumnTypeScanType(index int) reflect.Type {
+	if ct, ok := r.r.(driver.RowsColumnTypeScanType); ok {
+		return ct.ColumnTypeScanType(index)
+	}
+	return reflect.TypeOf(new(any)).Elem()
+}
+
+func (r *rows) ColumnTypeDatabaseTypeName(index int) string {
+	if ct, ok := r.r.(driver.RowsColumnTypeDatabaseTypeName); ok {
+		return ct.ColumnTypeDatabaseTypeName(index)
+	}
+	return ""
+}
+
+func (r *rows) ColumnTypeLength(index int) (length int64, ok bool) {
+	if ct, ok := r.r.(driver.RowsColumnTypeLength); ok {
+		return ct.ColumnTypeLength(index)
+	}
+	return 0, false
+}
+
+func (r *rows) ColumnTypeNullable(index int) (nullable, ok bool) {
+	if ct, ok := r.r.(driver.RowsColumnTypeNullable); ok {
+		return ct.ColumnTypeNullable(index)
+	}
+	return false, false
+}
+
+func (r *rows) ColumnTypePrecisionScale(index int) (precision, scale int64, ok bool) {
+	if ct, ok := r.r.(driver.RowsColumnTypePrecisionScale); ok {
+		return ct.ColumnTypePrecisionScale(index)
+	}
+	return 0, 0, false
+}
@@ -0,0 +1,115 @@
+package dbreaker
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+func TestRowsColumnTypes(t *testing.T) {
+	const driver = "wrapper-rows"
+	ctx := context.Background()
+	_, native := newMock()
+	if _, err := NewDriver(driver, native); err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open(driver, "rows")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	rows, err := db.QueryContext(ctx, "select value from things")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(types) != 1 {
+		t.Fatalf("expected 1 column but got: %d", len(types))
+	}
+	if name := types[0].DatabaseTypeName(); name != "TEXT" {
+		t.Fatalf("expected database type name %q but got: %q", "TEXT", name)
+	}
+	if _, ok := types[0].Nullable(); ok {
+		t.Fatal("expected nullable to be unknown when the inner rows do not report it")
+	}
+	if rows.NextResultSet() {
+		t.Fatal("expected no further result sets")
+	}
+}
+
+func TestRowsRead(t *testing.T) {
+	const driver = "wrapper-rows-read"
+	ctx := context.Background()
+	drv, err := NewDriver(driver, "sqlite3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	breaker := drv.(*Breaker)
+	dsn := filepath.Join(t.TempDir(), "rows.db")
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	if _, err := db.ExecContext(ctx, "create table users (id integer primary key)"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 5; i++ {
+		if _, err := db.ExecContext(ctx, "insert into users values(?)", i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// count reads the ids, calling down once stop of them are read
+	count := func(stop int, down func()) int {
+		t.Helper()
+		rows, err := db.QueryContext(ctx, "select id from users order by id")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rows.Close()
+		n := 0
+		for rows.Next() {
+			var id int
+			if err := rows.Scan(&id); err != nil {
+				t.Fatal(err)
+			}
+			if n++; n == stop {
+				down()
+			}
+		}
+		if err := rows.Err(); err != nil {
+			t.Fatalf("expected the rows to end cleanly but got: %v", err)
+		}
+		return n
+	}
+
+	disable := func() { breaker.Disable(true) }
+	if n := count(0, disable); n != 5 {
+		t.Fatalf("expected 5 rows but got: %d", n)
+	}
+	if n := breaker.Stats().RowsRead; n != 5 {
+		t.Fatalf("expected 5 rows read but got: %d", n)
+	}
+
+	// disabling the breaker ends the rows early
+	if n := count(2, disable); n != 2 {
+		t.Fatalf("expected 2 rows before the breaker was disabled but got: %d", n)
+	}
+	if n := breaker.Stats().RowsRead; n != 7 {
+		t.Fatalf("expected 7 rows read but got: %d", n)
+	}
+
+	// as does disabling the data source name, which leaves the state as it is
+	breaker.Disable(false)
+	if n := count(3, func() { breaker.DisableName(dsn, true) }); n != 3 {
+		t.Fatalf("expected 3 rows before the name was disabled but got: %d", n)
+	}
+}
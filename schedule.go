@@ -0,0 +1,152 @@
+package dbreaker
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ScheduleWindow disables the breaker for a maintenance window from start
+// until end, repeating every recur if it is non-zero.
+//
+// Windows that are already over are skipped, so a recurring schedule whose
+// start is in the past begins with the current or next occurrence. Windows
+// from separate schedules may overlap, the breaker stays down until the
+// last one ends. This is independent of Disable: a window ending does not
+// re-enable a driver that has been disabled by hand.
+//
+// The returned stop function cancels the schedule, ending any window in
+// progress, and waits for it to stop unless called from a state change hook
+// the schedule runs. It is safe to call more than once. Closing the breaker cancels
+// its schedules too, and no window is scheduled once it is closed.
+func (w *Breaker) ScheduleWindow(start, end time.Time, recur time.Duration) (stop func(), err error) {
+	if !end.After(start) {
+		return nil, fmt.Errorf("window end %v is not after start %v", end, start)
+	}
+	if recur < 0 || recur > 0 && recur < end.Sub(start) {
+		return nil, fmt.Errorf("window recurrence %v must be zero or at least the window length %v", recur, end.Sub(start))
+	}
+	if now := w.clock.Now(); !end.After(now) {
+		if recur == 0 {
+			return func() {}, nil
+		}
+		skip := (now.Sub(end)/recur + 1) * recur
+		start, end = start.Add(skip), end.Add(skip)
+	}
+
+	quit := make(chan struct{})
+	running := &watchGroup{}
+	w.mu.Lock()
+	if w.stopped {
+		w.mu.Unlock()
+		return func() {}, nil
+	}
+	w.watchers.Add(1)
+	running.Add(1)
+	go w.window(start, end, recur, quit, running)
+	w.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(quit) })
+		running.Wait()
+	}, nil
+}
+
+// window runs a schedule until it is over, quit is closed or the breaker is closed
+func (w *Breaker) window(start, end time.Time, recur time.Duration, quit chan struct{}, running *watchGroup) {
+	defer w.watchers.Done()
+	defer running.Done()
+	// the hooks may call stop or Close, which must not wait for them
+	notify := func() { running.aside(w.notifyAside) }
+	for {
+		if !w.sleep(start.Sub(w.clock.Now()), quit) {
+			return
+		}
+		w.windows.Add(1)
+		notify()
+		ok := w.sleep(end.Sub(w.clock.Now()), quit)
+		w.windows.Add(-1)
+		notify()
+		if !ok || recur == 0 {
+			return
+		}
+		start, end = start.Add(recur), end.Add(recur)
+	}
+}
+
+// DisableFor disables the breaker for d, then enables it again as
+// Disable(false) would, using the breaker's clock. Calling Disable,
+// DisableFor or LoadState before then cancels the re-enable.
+func (w *Breaker) DisableFor(d time.Duration) {
+	cancel := make(chan struct{})
+	w.mu.Lock()
+	w.cancelReenable()
+	if !w.stopped {
+		w.reenable = cancel
+		w.watchers.Add(1)
+		go w.reenableAfter(d, cancel)
+	}
+	changed := w.disable(true)
+	w.mu.Unlock()
+	if changed {
+		w.notify()
+	}
+}
+
+// reenableAfter enables the breaker once d has passed,
+// unless cancel or the breaker is closed first
+func (w *Breaker) reenableAfter(d time.Duration, cancel chan struct{}) {
+	defer w.watchers.Done()
+	select {
+	case <-w.clock.After(d):
+	case <-cancel:
+		return
+	case <-w.quit:
+		return
+	}
+	w.mu.Lock()
+	if w.reenable != cancel {
+		// cancelled while waiting for the lock
+		w.mu.Unlock()
+		return
+	}
+	w.reenable = nil
+	changed := w.disable(false)
+	w.mu.Unlock()
+	if changed {
+		w.notifyAside()
+	}
+}
+
+// cancelReenable cancels the re-enable scheduled by DisableFor, if any.
+// The caller must hold the lock.
+func (w *Breaker) cancelReenable() {
+	if w.reenable != nil {
+		close(w.reenable)
+		w.reenable = nil
+	}
+}
+
+// sleep waits for d to pass, returning false if quit
+// or the breaker is closed first
+func (w *Breaker) sleep(d time.Duration, quit <-chan struct{}) bool {
+	if d <= 0 {
+		select {
+		case <-quit:
+			return false
+		case <-w.quit:
+			return false
+		default:
+			return true
+		}
+	}
+	select {
+	case <-w.clock.After(d):
+		return true
+	case <-quit:
+		return false
+	case <-w.quit:
+		return false
+	}
+}
@@ -0,0 +1,238 @@
+package dbreaker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleWindow(t *testing.T) {
+	clk := newFakeClock()
+	w := newBreaker("sqlite3", WithClock(clk))
+
+	start := clk.Now().Add(time.Hour)
+	stop, err := w.ScheduleWindow(start, start.Add(time.Hour), 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	expect := func(down bool) {
+		t.Helper()
+		// wait for the scheduler to settle on its next timer
+		clk.BlockUntil(1)
+		if w.IsDown() != down {
+			t.Fatalf("at %v expected down to be %v", clk.Now(), down)
+		}
+	}
+
+	expect(false)
+	clk.Advance(time.Hour)
+	expect(true)
+	if state := w.State(); state != Open {
+		t.Fatalf("expected state %v but got: %v", Open, state)
+	}
+	clk.Advance(time.Hour)
+	expect(false)
+
+	// the next day's window
+	clk.Advance(22 * time.Hour)
+	expect(false)
+	clk.Advance(time.Hour)
+	expect(true)
+
+	// stopping ends the window in progress
+	stop()
+	if w.IsDown() {
+		t.Fatal("expected the breaker to be up after stopping the schedule")
+	}
+}
+
+func TestScheduleWindowPast(t *testing.T) {
+	clk := newFakeClock()
+	w := newBreaker("sqlite3", WithClock(clk))
+
+	// a one-off window that is already over does nothing
+	start := clk.Now().Add(-2 * time.Hour)
+	stop, err := w.ScheduleWindow(start, start.Add(time.Hour), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop()
+
+	// a recurring one picks up the occurrence in progress
+	stop, err = w.ScheduleWindow(start, start.Add(3*time.Hour), 4*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	clk.BlockUntil(1)
+	if !w.IsDown() {
+		t.Fatal("expected the breaker to be down during the window")
+	}
+	clk.Advance(time.Hour)
+	clk.BlockUntil(1)
+	if w.IsDown() {
+		t.Fatal("expected the breaker to be up after the window")
+	}
+}
+
+func TestScheduleWindowOverlap(t *testing.T) {
+	clk := newFakeClock()
+	w := newBreaker("sqlite3", WithClock(clk))
+
+	now := clk.Now()
+	stop1, err := w.ScheduleWindow(now, now.Add(2*time.Hour), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop1()
+	stop2, err := w.ScheduleWindow(now.Add(time.Hour), now.Add(3*time.Hour), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop2()
+
+	clk.BlockUntil(2)
+	clk.Advance(time.Hour)
+	clk.BlockUntil(2)
+	clk.Advance(time.Hour)
+	// stopping waits for the first schedule to finish its window
+	stop1()
+	if !w.IsDown() {
+		t.Fatal("expected the breaker to stay down until the last window ends")
+	}
+	clk.Advance(time.Hour)
+	stop2()
+	if w.IsDown() {
+		t.Fatal("expected the breaker to be up after both windows")
+	}
+}
+
+func TestScheduleWindowClose(t *testing.T) {
+	clk := newFakeClock()
+	w := newBreaker("sqlite3", WithClock(clk))
+
+	now := clk.Now()
+	stop, err := w.ScheduleWindow(now, now.Add(time.Hour), 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	clk.BlockUntil(1)
+	if !w.IsDown() {
+		t.Fatal("expected the breaker to be down during the window")
+	}
+
+	// closing the breaker ends the window in progress and the schedule
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if w.IsDown() {
+		t.Fatal("expected closing the breaker to end the window")
+	}
+	clk.Advance(24 * time.Hour)
+	if w.IsDown() {
+		t.Fatal("expected no window after the breaker is closed")
+	}
+
+	// and none are scheduled once it is closed
+	stop, err = w.ScheduleWindow(now, now.Add(48*time.Hour), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	if w.IsDown() {
+		t.Fatal("expected no window to be scheduled on a closed breaker")
+	}
+}
+
+func TestScheduleWindowHookStop(t *testing.T) {
+	clk := newFakeClock()
+	w := newBreaker("sqlite3", WithClock(clk))
+	defer w.Close()
+
+	start := clk.Now().Add(time.Hour)
+	stop, err := w.ScheduleWindow(start, start.Add(time.Hour), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stopped := make(chan struct{})
+	w.OnStateChange(func(old, new CircuitState) {
+		if new == Open {
+			stop()
+			close(stopped)
+		}
+	})
+
+	// the hook is run by the schedule as the window starts
+	clk.BlockUntil(1)
+	clk.Advance(time.Hour)
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("expected a hook run by the schedule to be able to stop it")
+	}
+	stop()
+	if w.IsDown() {
+		t.Fatal("expected the breaker to be up after stopping the schedule")
+	}
+}
+
+func TestScheduleWindowInvalid(t *testing.T) {
+	w := newBreaker("sqlite3")
+	now := time.Now()
+	if _, err := w.ScheduleWindow(now, now, 0); err == nil {
+		t.Error("expected an error for an empty window")
+	}
+	if _, err := w.ScheduleWindow(now, now.Add(time.Hour), time.Minute); err == nil {
+		t.Error("expected an error for a recurrence shorter than the window")
+	}
+}
+
+func TestDisableFor(t *testing.T) {
+	clk := newFakeClock()
+	w := newBreaker("sqlite3", WithClock(clk))
+	defer w.Close()
+
+	// up waits for the breaker to be enabled again
+	up := func() {
+		t.Helper()
+		deadline := time.Now().Add(time.Second)
+		for w.IsDown() {
+			if time.Now().After(deadline) {
+				t.Fatalf("at %v expected the breaker to be enabled again", clk.Now())
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	w.DisableFor(time.Minute)
+	clk.BlockUntil(1)
+	clk.Advance(time.Minute - time.Second)
+	if !w.IsDown() {
+		t.Fatal("expected the breaker to be down until the time is up")
+	}
+	clk.Advance(time.Second)
+	up()
+
+	// a later call replaces the earlier one
+	w.DisableFor(time.Minute)
+	w.DisableFor(time.Hour)
+	clk.BlockUntil(1)
+	clk.Advance(time.Minute)
+	if !w.IsDown() {
+		t.Fatal("expected the first re-enable to be cancelled")
+	}
+	clk.Advance(time.Hour)
+	up()
+
+	// Disable cancels it too
+	w.DisableFor(time.Minute)
+	w.Disable(false)
+	w.Disable(true)
+	clk.Advance(time.Minute)
+	time.Sleep(10 * time.Millisecond)
+	if !w.IsDown() {
+		t.Fatal("expected Disable to cancel the re-enable")
+	}
+}
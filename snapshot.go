@@ -0,0 +1,38 @@
+package dbreaker
+
+import "time"
+
+// Snapshot is the full status of a breaker at one moment, see Breaker.Snapshot
+type Snapshot struct {
+	Time     time.Time    // when it was taken, by the breaker's clock
+	State    CircuitState // as returned by State
+	Down     bool         // as returned by IsDown
+	ReadOnly bool         // set by SetReadOnly
+	Draining bool         // set by Drain
+	InFlight int          // operations and transactions under way
+	Saved    SavedState   // state set by the breaker's operators
+	Stats    Stats        // counters and the last trip and audit
+}
+
+// Snapshot returns the full status of the breaker in one call.
+//
+// Disable, DisableName, DisableFor and LoadState are held off while it is
+// taken, as are operations starting or finishing, so that the names
+// disabled and InFlight agree with the state reported. Counters of
+// operations refused meanwhile may still move, the stats are not locked.
+func (w *Breaker) Snapshot() Snapshot {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.imu.Lock()
+	defer w.imu.Unlock()
+	return Snapshot{
+		Time:     w.clock.Now(),
+		State:    w.state(),
+		Down:     w.IsDown(),
+		ReadOnly: w.readOnly.Load(),
+		Draining: w.draining.Load(),
+		InFlight: w.inflight,
+		Saved:    w.saved(),
+		Stats:    w.Stats(),
+	}
+}
@@ -0,0 +1,58 @@
+package dbreaker
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSnapshot(t *testing.T) {
+	const wrapper = "wrapper-snapshot"
+	ctx := context.Background()
+	clk := newFakeClock()
+	_, native := newMock()
+	drv, err := NewDriverWithOptions(wrapper, native, WithClock(clk), WithReadOnly(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	breaker := drv.(*Breaker)
+	db, err := sql.Open(wrapper, "snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err := db.Begin(); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("expected %v but got: %v", ErrReadOnly, err)
+	}
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	breaker.DisableName("replica", true)
+	breaker.ForceOpen("migration")
+
+	snap := breaker.Snapshot()
+	if !snap.Time.Equal(clk.Now()) || snap.State != Open || !snap.Down || !snap.ReadOnly || snap.Draining {
+		t.Fatalf("unexpected status: %+v", snap)
+	}
+	if snap.InFlight != 1 {
+		t.Fatalf("expected the transaction in flight but got: %d", snap.InFlight)
+	}
+	want := SavedState{Forced: true, Reason: "migration", Names: []string{"replica"}}
+	if !reflect.DeepEqual(snap.Saved, want) {
+		t.Fatalf("expected saved state %+v but got: %+v", want, snap.Saved)
+	}
+	if snap.Stats.BlockedExecs != 1 || snap.Stats.AllowedOpens != 1 || snap.Stats.Reason != "migration" {
+		t.Fatalf("unexpected stats: %+v", snap.Stats)
+	}
+
+	tx.Rollback()
+	breaker.ForceClose()
+	if snap := breaker.Snapshot(); snap.State != Closed || snap.Down || snap.InFlight != 0 || snap.Saved.Forced {
+		t.Fatalf("expected the breaker closed and idle but got: %+v", snap)
+	}
+}
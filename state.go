@@ -0,0 +1,95 @@
+package dbreaker
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// SavedState is the state of a breaker set by its operators, as opposed to
+// that of the automatic circuit breaker, for persisting with SnapshotState
+// and restoring with LoadState.
+//
+// It marshals to JSON as
+//
+//	{"down": true, "forced": true, "reason": "migration", "names": ["orders"]}
+//
+// with names sorted and fields that are not set left out.
+type SavedState struct {
+	Down   bool     // set by Disable
+	Forced bool     // set by ForceOpen, with Reason
+	Reason string   // given to ForceOpen
+	Names  []string // disabled by DisableName
+}
+
+// savedState is the JSON encoding of SavedState
+type savedState struct {
+	Down   bool     `json:"down,omitempty"`
+	Forced bool     `json:"forced,omitempty"`
+	Reason string   `json:"reason,omitempty"`
+	Names  []string `json:"names,omitempty"`
+}
+
+// MarshalJSON satisfies the json.Marshaler interface
+func (s SavedState) MarshalJSON() ([]byte, error) {
+	names := append([]string(nil), s.Names...)
+	sort.Strings(names)
+	return json.Marshal(savedState{Down: s.Down, Forced: s.Forced, Reason: s.Reason, Names: names})
+}
+
+// UnmarshalJSON satisfies the json.Unmarshaler interface
+func (s *SavedState) UnmarshalJSON(data []byte) error {
+	var saved savedState
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return err
+	}
+	*s = SavedState(saved)
+	return nil
+}
+
+// SnapshotState returns the state of the breaker set by Disable, ForceOpen
+// and DisableName
+func (w *Breaker) SnapshotState() SavedState {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.saved()
+}
+
+// saved returns the state set by the breaker's operators.
+// The caller must hold the lock.
+func (w *Breaker) saved() SavedState {
+	s := SavedState{Down: w.down.Load()}
+	if reason := w.forced.Load(); reason != nil {
+		s.Forced, s.Reason = true, *reason
+	}
+	for name := range w.offline {
+		s.Names = append(s.Names, name)
+	}
+	sort.Strings(s.Names)
+	return s
+}
+
+// LoadState restores state saved by SnapshotState, replacing that set by
+// Disable, ForceOpen and DisableName. Names not in s are enabled, and a
+// breaker not forced open in s is not reset the way ForceClose does.
+// A re-enable scheduled by DisableFor is cancelled.
+//
+// The whole of s is applied before any state change is reported, so hooks
+// and events see a single change from the old state to the new one.
+func (w *Breaker) LoadState(s SavedState) {
+	w.mu.Lock()
+	w.cancelReenable()
+	w.offline = make(map[string]bool, len(s.Names))
+	for _, name := range s.Names {
+		w.offline[name] = true
+	}
+	if s.Forced {
+		reason := s.Reason
+		w.forced.Store(&reason)
+	} else {
+		w.forced.Store(nil)
+	}
+	w.down.Store(s.Down)
+	w.gen.Add(1)
+	w.mu.Unlock()
+	w.notify()
+}
@@ -0,0 +1,57 @@
+package dbreaker
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestSavedStateRoundTrip(t *testing.T) {
+	_, native := newMock()
+	source := newBreaker(native)
+	source.Disable(true)
+	source.ForceOpen("migration")
+	source.DisableName("orders", true)
+	source.DisableName("billing", true)
+
+	data, err := json.Marshal(source.SnapshotState())
+	if err != nil {
+		t.Fatal(err)
+	}
+	const expect = `{"down":true,"forced":true,"reason":"migration","names":["billing","orders"]}`
+	if string(data) != expect {
+		t.Fatalf("expected %s but got: %s", expect, data)
+	}
+
+	var saved SavedState
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatal(err)
+	}
+	target := newBreaker(native)
+	var changes []CircuitState
+	target.OnStateChange(func(old, new CircuitState) {
+		changes = append(changes, new)
+	})
+	target.LoadState(saved)
+	if got := target.SnapshotState(); !reflect.DeepEqual(got, source.SnapshotState()) {
+		t.Fatalf("expected state %+v but got: %+v", source.SnapshotState(), got)
+	}
+	if !target.IsDown() || target.Reason() != "migration" || !target.IsNameDown("orders") {
+		t.Fatal("expected the loaded state to take effect")
+	}
+	if !reflect.DeepEqual(changes, []CircuitState{Open}) {
+		t.Fatalf("expected a single change to %v but got: %v", Open, changes)
+	}
+
+	// loading the zero state enables everything again
+	target.LoadState(SavedState{})
+	if target.IsDown() || target.Reason() != "" || target.nameDown("orders") {
+		t.Fatal("expected the breaker to be enabled")
+	}
+	if data, _ := json.Marshal(target.SnapshotState()); string(data) != `{}` {
+		t.Fatalf("expected an empty state but got: %s", data)
+	}
+	if !reflect.DeepEqual(changes, []CircuitState{Open, Closed}) {
+		t.Fatalf("expected changes to %v and %v but got: %v", Open, Closed, changes)
+	}
+}
@@ -0,0 +1,206 @@
+package dbreaker
+
+import (
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// operations as reported by the breaker's counters
+const (
+	opOpen  = "open"
+	opExec  = "exec"
+	opQuery = "query"
+	opBegin = "begin"
+)
+
+// Stats is a snapshot of the breaker's counters
+type Stats struct {
+	AllowedOpens   uint64 // connections let through to the inner driver
+	BlockedOpens   uint64 // connections refused by the breaker
+	BlockedQueries uint64 // queries refused by the breaker
+	BlockedExecs   uint64 // execs and transactions refused by the breaker
+	Trips          uint64 // times the circuit tripped automatically
+	RowsRead       uint64 // rows read by queries let through
+	Reason         string // reason given to ForceOpen, while forced open
+
+	// LastTrip is when the circuit last tripped automatically,
+	// and LastError the error that tripped it
+	LastTrip  time.Time
+	LastError error
+
+	// LastAudit is who last disabled or enabled the breaker
+	// with DisableWithContext
+	LastAudit Audit
+}
+
+// counters are the live values behind Stats
+type counters struct {
+	allowedOpens   atomic.Uint64
+	blockedOpens   atomic.Uint64
+	blockedQueries atomic.Uint64
+	blockedExecs   atomic.Uint64
+	trips          atomic.Uint64
+	rowsRead       atomic.Uint64
+	lastTrip       atomic.Pointer[trip]
+	lastAudit      atomic.Pointer[Audit]
+}
+
+// trip records an automatic trip of the circuit
+type trip struct {
+	at  time.Time
+	err error
+}
+
+// load returns the values of the counters, without Reason and LastAudit
+func (c *counters) load() Stats {
+	stats := Stats{
+		AllowedOpens:   c.allowedOpens.Load(),
+		BlockedOpens:   c.blockedOpens.Load(),
+		BlockedQueries: c.blockedQueries.Load(),
+		BlockedExecs:   c.blockedExecs.Load(),
+		Trips:          c.trips.Load(),
+		RowsRead:       c.rowsRead.Load(),
+	}
+	if last := c.lastTrip.Load(); last != nil {
+		stats.LastTrip = last.at
+		stats.LastError = last.err
+	}
+	return stats
+}
+
+// zero zeroes the counters, keeping the last trip
+func (c *counters) zero() {
+	c.allowedOpens.Store(0)
+	c.blockedOpens.Store(0)
+	c.blockedQueries.Store(0)
+	c.blockedExecs.Store(0)
+	c.trips.Store(0)
+	c.rowsRead.Store(0)
+}
+
+// namedStats are the counters kept for each data source name
+type namedStats struct {
+	mu    sync.Mutex
+	names map[string]*counters
+}
+
+// of returns the counters of name, creating them on first use
+func (n *namedStats) of(name string) *counters {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	c, ok := n.names[name]
+	if !ok {
+		if n.names == nil {
+			n.names = make(map[string]*counters)
+		}
+		c = &counters{}
+		n.names[name] = c
+	}
+	return c
+}
+
+// each calls fn with the counters of every name
+func (n *namedStats) each(fn func(c *counters)) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, c := range n.names {
+		fn(c)
+	}
+}
+
+// Stats returns a snapshot of the breaker's counters.
+//
+// Each counter is read atomically, so values are never torn, but
+// the snapshot as a whole is not taken at a single instant.
+// Prepared statements are counted as queries or execs by their leading
+// keyword, and transactions are counted as execs.
+func (w *Breaker) Stats() Stats {
+	return w.withState(w.stats.load())
+}
+
+// StatsForName returns a snapshot of the breaker's counters for the
+// operations on name alone, as given to Open or OpenConnector, while Stats
+// counts those on every name. Operations on a fallback database count
+// toward the name they stand in for, and trips toward the name of the
+// operation that tripped the circuit, which is shared by all names.
+// Reason and LastAudit are those of the breaker as a whole.
+func (w *Breaker) StatsForName(name string) Stats {
+	var stats Stats
+	w.named.mu.Lock()
+	if c, ok := w.named.names[name]; ok {
+		stats = c.load()
+	}
+	w.named.mu.Unlock()
+	return w.withState(stats)
+}
+
+// withState adds the breaker's Reason and LastAudit to stats
+func (w *Breaker) withState(stats Stats) Stats {
+	stats.Reason = w.Reason()
+	if audit := w.stats.lastAudit.Load(); audit != nil {
+		stats.LastAudit = *audit
+	}
+	return stats
+}
+
+// ResetStats zeroes the breaker's counters, e.g. to compute rates over intervals,
+// those for each name included. The last trip is kept.
+func (w *Breaker) ResetStats() {
+	w.stats.zero()
+	w.named.each((*counters).zero)
+}
+
+// count calls fn with the breaker's counters and then those of name
+func (w *Breaker) count(name string, fn func(c *counters)) {
+	fn(&w.stats)
+	fn(w.named.of(name))
+}
+
+// blocked records op on name as refused with err, and returns err
+func (w *Breaker) blocked(op, name string, err error) error {
+	return w.refuse(op, name, "", err)
+}
+
+// refuse is blocked for op running query, reported by its fingerprint
+func (w *Breaker) refuse(op, name, query string, err error) error {
+	w.count(name, func(c *counters) {
+		switch op {
+		case opOpen:
+			c.blockedOpens.Add(1)
+		case opQuery:
+			c.blockedQueries.Add(1)
+		default:
+			c.blockedExecs.Add(1)
+		}
+	})
+	args := []any{"dsn", name, "op", op}
+	if query != "" {
+		query = w.fingerprintOf(query)
+		args = append(args, "query", query)
+	}
+	w.log(slog.LevelDebug, "dbreaker blocked operation", append(args, "error", err)...)
+	w.emit(Event{Type: EventBlocked, DSN: name, Op: op, Query: query, Err: err})
+	w.blocks.add(BlockedOp{Time: w.clock.Now(), DSN: name, Op: op, Query: query, Err: err})
+	if w.onBlocked != nil {
+		w.guard("blocked hook", func() { w.onBlocked(op, query, name) })
+	}
+	return err
+}
+
+// restrict is refuse for the read-only and category blocks, which in
+// dry-run mode are only reported and return nil to let op through
+func (w *Breaker) restrict(op, name, query string, err error) error {
+	if !w.dryRun {
+		return w.refuse(op, name, query, err)
+	}
+	args := []any{"dsn", name, "op", op}
+	if query != "" {
+		query = w.fingerprintOf(query)
+		args = append(args, "query", query)
+	}
+	w.log(slog.LevelInfo, "dbreaker would block operation", append(args, "error", err)...)
+	w.emit(Event{Type: EventWouldBlock, DSN: name, Op: op, Query: query, Err: err})
+	return nil
+}
@@ -0,0 +1,267 @@
+package dbreaker
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	const wrapper = "wrapper-stats"
+	ctx := context.Background()
+	mock, native := newMock()
+	drv, err := NewDriverWithOptions(wrapper, native, WithFailureThreshold(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	breaker := drv.(*Breaker)
+	db, err := sql.Open(wrapper, "stats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	breaker.Disable(true)
+	for i := 0; i < 2; i++ {
+		conn.ExecContext(ctx, "insert into users values(1)")
+	}
+	for i := 0; i < 3; i++ {
+		conn.QueryContext(ctx, "select * from users")
+	}
+	conn.BeginTx(ctx, nil)
+	conn.PrepareContext(ctx, "select * from users")
+	for i := 0; i < 4; i++ {
+		breaker.Open("stats")
+	}
+
+	// trip the circuit automatically
+	breaker.Disable(false)
+	mock.Fail(errMock)
+	conn.ExecContext(ctx, "insert into users values(1)")
+
+	expect := Stats{
+		AllowedOpens:   1,
+		BlockedOpens:   4,
+		BlockedQueries: 4,
+		BlockedExecs:   3,
+		Trips:          1,
+	}
+	stats := breaker.Stats()
+	stats.LastTrip, stats.LastError = time.Time{}, nil
+	if stats != expect {
+		t.Fatalf("expected stats %+v but got: %+v", expect, stats)
+	}
+}
+
+func TestStatsLastTrip(t *testing.T) {
+	const wrapper = "wrapper-stats-trip"
+	ctx := context.Background()
+	clk := newFakeClock()
+	mock, native := newMock()
+	drv, err := NewDriverWithOptions(wrapper, native,
+		WithClock(clk),
+		WithFailureThreshold(1),
+		WithResetTimeout(time.Minute),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	breaker := drv.(*Breaker)
+	db, err := sql.Open(wrapper, "stats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if stats := breaker.Stats(); !stats.LastTrip.IsZero() || stats.LastError != nil {
+		t.Fatalf("expected no last trip but got: %v %v", stats.LastTrip, stats.LastError)
+	}
+	mock.Fail(errMock)
+	db.ExecContext(ctx, "insert into users values(1)")
+	tripped := clk.Now()
+
+	// the last trip outlives the circuit closing again and reset counters
+	mock.Fail(nil)
+	clk.Advance(time.Minute)
+	if _, err := db.ExecContext(ctx, "insert into users values(1)"); err != nil {
+		t.Fatal(err)
+	}
+	breaker.ResetStats()
+	stats := breaker.Stats()
+	if !stats.LastTrip.Equal(tripped) {
+		t.Fatalf("expected last trip at %v but got: %v", tripped, stats.LastTrip)
+	}
+	if stats.LastError != errMock {
+		t.Fatalf("expected last error %v but got: %v", errMock, stats.LastError)
+	}
+}
+
+func TestResetStats(t *testing.T) {
+	const wrapper = "wrapper-stats-reset"
+	ctx := context.Background()
+	_, native := newMock()
+	drv, err := NewDriver(wrapper, native)
+	if err != nil {
+		t.Fatal(err)
+	}
+	breaker := drv.(*Breaker)
+	db, err := sql.Open(wrapper, "stats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// hammer the counters while they are read and reset
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			breaker.Stats()
+			breaker.ResetStats()
+		}
+	}()
+	breaker.Disable(true)
+	for i := 0; i < 100; i++ {
+		conn.ExecContext(ctx, "insert into users values(1)")
+		conn.QueryContext(ctx, "select * from users")
+		breaker.Open("stats")
+	}
+	<-done
+
+	breaker.ResetStats()
+	if stats := breaker.Stats(); stats != (Stats{}) {
+		t.Fatalf("expected zeroed stats but got: %+v", stats)
+	}
+	conn.ExecContext(ctx, "insert into users values(1)")
+	if stats := breaker.Stats(); stats.BlockedExecs != 1 {
+		t.Fatalf("expected counting to resume after reset but got: %+v", stats)
+	}
+}
+
+func TestWithOnBlocked(t *testing.T) {
+	const wrapper = "wrapper-on-blocked"
+	ctx := context.Background()
+	type call struct{ op, query, dsn string }
+	var calls []call
+	mock, native := newMock()
+	drv, err := NewDriverWithOptions(wrapper, native,
+		WithFailureThreshold(1),
+		WithResetTimeout(time.Hour),
+		WithOnBlocked(func(op, query, dsn string) {
+			calls = append(calls, call{op, query, dsn})
+			if op == opOpen {
+				panic("hook")
+			}
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	breaker := drv.(*Breaker)
+	db, err := sql.Open(wrapper, "on-blocked")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	mock.Fail(errMock)
+	conn.ExecContext(ctx, "insert into users values(1)")
+	if state := breaker.State(); state != Open {
+		t.Fatalf("expected state %v but got: %v", Open, state)
+	}
+	conn.ExecContext(ctx, "update users set id=2")
+	conn.QueryContext(ctx, "select * from users where id=1")
+	conn.BeginTx(ctx, nil)
+	if _, err := breaker.Open("on-blocked"); !errors.Is(err, ErrDown) {
+		t.Fatalf("expected %v despite the panicking hook but got: %v", ErrDown, err)
+	}
+
+	expect := []call{
+		{opExec, "update users set id = ?", "on-blocked"},
+		{opQuery, "select * from users where id = ?", "on-blocked"},
+		{opBegin, "", "on-blocked"},
+		{opOpen, "", "on-blocked"},
+	}
+	if len(calls) != len(expect) {
+		t.Fatalf("expected calls %v but got: %v", expect, calls)
+	}
+	for i := range expect {
+		if calls[i] != expect[i] {
+			t.Fatalf("expected call %d to be %v but got: %v", i, expect[i], calls[i])
+		}
+	}
+}
+
+func TestStatsForName(t *testing.T) {
+	const wrapper = "wrapper-stats-for-name"
+	ctx := context.Background()
+	_, native := newMock()
+	drv, err := NewDriver(wrapper, native)
+	if err != nil {
+		t.Fatal(err)
+	}
+	breaker := drv.(*Breaker)
+	open := func(name string) *sql.Conn {
+		t.Helper()
+		db, err := sql.Open(wrapper, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		conn, err := db.Conn(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		return conn
+	}
+	orders, users := open("orders"), open("users")
+
+	breaker.DisableName("orders", true)
+	for i := 0; i < 2; i++ {
+		if _, err := orders.ExecContext(ctx, "insert into orders values(1)"); !errors.Is(err, ErrDown) {
+			t.Fatalf("expected %v but got: %v", ErrDown, err)
+		}
+	}
+	breaker.DisableName("orders", false)
+	breaker.DisableName("users", true)
+	if _, err := users.QueryContext(ctx, "select * from users"); !errors.Is(err, ErrDown) {
+		t.Fatalf("expected %v but got: %v", ErrDown, err)
+	}
+
+	for name, want := range map[string]Stats{
+		"orders": {AllowedOpens: 1, BlockedExecs: 2},
+		"users":  {AllowedOpens: 1, BlockedQueries: 1},
+		"other":  {},
+	} {
+		if got := breaker.StatsForName(name); got != want {
+			t.Fatalf("expected stats %+v for %s but got: %+v", want, name, got)
+		}
+	}
+	want := Stats{AllowedOpens: 2, BlockedExecs: 2, BlockedQueries: 1}
+	if got := breaker.Stats(); got != want {
+		t.Fatalf("expected stats %+v in all but got: %+v", want, got)
+	}
+
+	breaker.ResetStats()
+	if got := breaker.StatsForName("orders"); got != (Stats{}) {
+		t.Fatalf("expected the stats for a name reset but got: %+v", got)
+	}
+}
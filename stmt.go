@@ -0,0 +1,118 @@
+package dbreaker
+
+import (
+	"context"
+	"database/sql/driver"
+)
+
+// stmt wraps a prepared statement so it honors the breaker
+// for as long as it lives, not just when it was prepared
+type stmt struct {
+	s     driver.Stmt
+	c     *Conn
+	query string
+}
+
+// Close closes the inner statement
+func (s *stmt) Close() error {
+	return s.s.Close()
+}
+
+// NumInput returns the number of placeholder parameters
+func (s *stmt) NumInput() int {
+	return s.s.NumInput()
+}
+
+// Exec executes a query that doesn't return rows, such as an INSERT or UPDATE.
+func (s *stmt) Exec(args []driver.Value) (res driver.Result, err error) {
+	probe, err := s.c.allow(context.Background(), opExec, s.query)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { s.c.done(probe, err) }()
+	return s.s.Exec(args)
+}
+
+// Query executes a query that may return rows, such as a SELECT.
+func (s *stmt) Query(args []driver.Value) (rows driver.Rows, err error) {
+	probe, err := s.c.allow(context.Background(), opQuery, s.query)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { s.c.done(probe, err) }()
+	return s.s.Query(args)
+}
+
+// ExecContext executes a query that doesn't return rows, such as an INSERT or UPDATE.
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (res driver.Result, err error) {
+	probe, err := s.c.allow(ctx, opExec, s.query)
+	if err != nil {
+		return nil, err
+	}
+	caller := ctx
+	ctx, cancel := s.c.w.withTimeout(ctx)
+	if cancel != nil {
+		defer cancel()
+	}
+	defer func() { s.c.done(probe, s.c.w.outcome(caller, ctx, err)) }()
+	return s.result(ctx, args)
+}
+
+// result delegates ExecContext to the inner statement
+func (s *stmt) result(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	if execer, ok := s.s.(driver.StmtExecContext); ok {
+		return execer.ExecContext(ctx, args)
+	}
+	values, err := namedValues(args)
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+	return s.s.Exec(values)
+}
+
+// QueryContext executes a query that may return rows, such as a SELECT.
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (rows driver.Rows, err error) {
+	probe, err := s.c.allow(ctx, opQuery, s.query)
+	if err != nil {
+		return nil, err
+	}
+	caller := ctx
+	ctx, cancel := s.c.w.withTimeout(ctx)
+	defer func() { s.c.done(probe, s.c.w.outcome(caller, ctx, err)) }()
+	rows, err = s.rows(ctx, args)
+	return s.c.bind(ctx, rows, err, cancel)
+}
+
+// rows delegates QueryContext to the inner statement
+func (s *stmt) rows(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	if queryer, ok := s.s.(driver.StmtQueryContext); ok {
+		return queryer.QueryContext(ctx, args)
+	}
+	values, err := namedValues(args)
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+	return s.s.Query(values)
+}
+
+// CheckNamedValue defers to the inner statement's checker, then the
+// connection's, and finally the sql package's default conversions.
+//
+// The sql package only consults the connection when the statement
+// is not a NamedValueChecker, so the wrapper has to do that itself.
+func (s *stmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := s.s.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	return s.c.CheckNamedValue(nv)
+}
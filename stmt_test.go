@@ -0,0 +1,178 @@
+package dbreaker
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestStmtDisabled(t *testing.T) {
+	const (
+		driver  = "wrapper-stmt"
+		create  = "create table if not exists users (id integer primary key, first_name text, last_name text)"
+		prepare = "insert into users (first_name, last_name) values(?, ?)"
+	)
+	breaker, err := NewDriver(driver, "sqlite3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open(driver, filepath.Join(t.TempDir(), "stmt.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(create); err != nil {
+		t.Fatal("exec fail:", err)
+	}
+
+	stmt, err := db.Prepare(prepare)
+	if err != nil {
+		t.Fatal("prepare fail:", err)
+	}
+	defer stmt.Close()
+	if _, err := stmt.Exec("joey", "ramone"); err != nil {
+		t.Fatal("exec fail:", err)
+	}
+
+	// a statement prepared before the breaker trips must not get through
+	breaker.Disable(true)
+	if _, err := stmt.Exec("dee dee", "ramone"); !errors.Is(err, ErrDown) {
+		t.Fatalf("expected %v but got: %v", ErrDown, err)
+	}
+	if _, err := stmt.Query("dee dee", "ramone"); !errors.Is(err, ErrDown) {
+		t.Fatalf("expected %v but got: %v", ErrDown, err)
+	}
+
+	breaker.Disable(false)
+	if _, err := stmt.Exec("dee dee", "ramone"); err != nil {
+		t.Fatal("exec fail:", err)
+	}
+}
+
+func TestStmtCheckNamedValue(t *testing.T) {
+	const driver = "wrapper-stmt-checker"
+	mock, native := newMock()
+	if _, err := NewDriver(driver, native); err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open(driver, "checker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	stmt, err := db.Prepare("insert into points values(?)")
+	if err != nil {
+		t.Fatal("prepare fail:", err)
+	}
+	defer stmt.Close()
+	if _, err := stmt.Exec(point{3, 4}); err != nil {
+		t.Fatal("exec fail:", err)
+	}
+	const expect = "insert into points values(?)[{ 1 (3,4)}]"
+	if execs := mock.Execs(); len(execs) != 1 || execs[0] != expect {
+		t.Fatalf("expected [%q] but got: %q", expect, execs)
+	}
+}
+
+func TestStmtExecContext(t *testing.T) {
+	const (
+		driver  = "wrapper-stmt-ctx"
+		create  = "create table if not exists users (id integer primary key, first_name text, last_name text)"
+		prepare = "insert into users (first_name, last_name) values(:first, :last)"
+		query   = "select count(*) from users where last_name = :last"
+	)
+	ctx := context.Background()
+	breaker, err := NewDriver(driver, "sqlite3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open(driver, filepath.Join(t.TempDir(), "stmt.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.ExecContext(ctx, create); err != nil {
+		t.Fatal("exec fail:", err)
+	}
+
+	ins, err := db.PrepareContext(ctx, prepare)
+	if err != nil {
+		t.Fatal("prepare fail:", err)
+	}
+	defer ins.Close()
+	sel, err := db.PrepareContext(ctx, query)
+	if err != nil {
+		t.Fatal("prepare fail:", err)
+	}
+	defer sel.Close()
+
+	count := func() int {
+		t.Helper()
+		var n int
+		if err := sel.QueryRowContext(ctx, sql.Named("last", "ramone")).Scan(&n); err != nil {
+			t.Fatal("query fail:", err)
+		}
+		return n
+	}
+
+	if _, err := ins.ExecContext(ctx, sql.Named("first", "joey"), sql.Named("last", "ramone")); err != nil {
+		t.Fatal("exec fail:", err)
+	}
+	if n := count(); n != 1 {
+		t.Fatalf("expected 1 row but got: %d", n)
+	}
+
+	breaker.Disable(true)
+	if _, err := ins.ExecContext(ctx, sql.Named("first", "dee dee"), sql.Named("last", "ramone")); !errors.Is(err, ErrDown) {
+		t.Fatalf("expected %v but got: %v", ErrDown, err)
+	}
+	if _, err := sel.QueryContext(ctx, sql.Named("last", "ramone")); !errors.Is(err, ErrDown) {
+		t.Fatalf("expected %v but got: %v", ErrDown, err)
+	}
+
+	breaker.Disable(false)
+	if _, err := ins.ExecContext(ctx, sql.Named("first", "dee dee"), sql.Named("last", "ramone")); err != nil {
+		t.Fatal("exec fail:", err)
+	}
+	if n := count(); n != 2 {
+		t.Fatalf("expected 2 rows but got: %d", n)
+	}
+}
+
+func TestStmtLegacyFallback(t *testing.T) {
+	const driver = "wrapper-stmt-legacy"
+	mock, native := newMock()
+	breaker, err := NewDriver(driver, native)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open(driver, "legacy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// the mock statement only implements the non-context Exec
+	stmt, err := db.Prepare("insert into users values(?)")
+	if err != nil {
+		t.Fatal("prepare fail:", err)
+	}
+	defer stmt.Close()
+	ctx := context.Background()
+	if _, err := stmt.ExecContext(ctx, "joey"); err != nil {
+		t.Fatal("exec fail:", err)
+	}
+	if n := len(mock.Execs()); n != 1 {
+		t.Fatalf("expected 1 exec but got: %d", n)
+	}
+	if _, err := stmt.ExecContext(ctx, sql.Named("name", "joey")); err == nil {
+		t.Fatal("expected named parameters to be rejected")
+	}
+	breaker.Disable(true)
+	if _, err := stmt.ExecContext(ctx, "joey"); !errors.Is(err, ErrDown) {
+		t.Fatalf("expected %v but got: %v", ErrDown, err)
+	}
+}
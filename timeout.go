@@ -0,0 +1,36 @@
+package dbreaker
+
+import (
+	"context"
+	"time"
+)
+
+// withTimeout bounds ctx by the operation timeout, unless the caller's
+// deadline is already as soon. The returned cancel is nil if ctx is unchanged.
+func (w *Breaker) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if w.timeout <= 0 {
+		return ctx, nil
+	}
+	if deadline, ok := ctx.Deadline(); ok && !deadline.After(time.Now().Add(w.timeout)) {
+		return ctx, nil
+	}
+	return context.WithTimeout(ctx, w.timeout)
+}
+
+// outcome returns the result of an operation as the circuit should count it,
+// given the caller's context and ctx, the one bounded by withTimeout that the
+// operation ran with. A failure once the caller has given up is none of the
+// database's doing and counts neither way, while an operation that overran
+// the operation timeout fails even if the driver ignored it.
+func (w *Breaker) outcome(caller, ctx context.Context, err error) error {
+	if caller.Err() != nil {
+		if err != nil {
+			return errNeutral
+		}
+		return nil
+	}
+	if err == nil && w.timeout > 0 && ctx.Err() == context.DeadlineExceeded {
+		return ctx.Err()
+	}
+	return err
+}
@@ -0,0 +1,122 @@
+package dbreaker
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestOperationTimeout(t *testing.T) {
+	const (
+		driver  = "wrapper-timeout"
+		timeout = 20 * time.Millisecond
+		insert  = "insert into users values(1)"
+	)
+	ctx := context.Background()
+	mock, native := newMock()
+	drv, err := NewDriverWithOptions(driver, native,
+		WithOperationTimeout(timeout),
+		WithFailureThreshold(2),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	breaker := drv.(*Breaker)
+	db, err := sql.Open(driver, "timeout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	mock.Slow(time.Second)
+
+	// the driver is given the timeout when the caller has no deadline
+	start := time.Now()
+	if _, err := db.ExecContext(ctx, insert); err != context.DeadlineExceeded {
+		t.Fatalf("expected %v but got: %v", context.DeadlineExceeded, err)
+	}
+	if elapsed := time.Since(start); elapsed >= time.Second {
+		t.Fatalf("expected the timeout to fire but exec took: %v", elapsed)
+	}
+
+	// a driver that ignores the deadline still has the overrun counted
+	rows, err := db.QueryContext(ctx, "select id from users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows.Close()
+	if state := breaker.State(); state != Open {
+		t.Fatalf("expected timeouts to trip the breaker but got: %v", state)
+	}
+}
+
+func TestOperationTimeoutShorterDeadline(t *testing.T) {
+	const driver = "wrapper-timeout-deadline"
+	mock, native := newMock()
+	if _, err := NewDriverWithOptions(driver, native, WithOperationTimeout(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open(driver, "timeout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	mock.Slow(time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := db.ExecContext(ctx, "insert into users values(1)"); err != context.DeadlineExceeded {
+		t.Fatalf("expected %v but got: %v", context.DeadlineExceeded, err)
+	}
+	if elapsed := time.Since(start); elapsed >= time.Second {
+		t.Fatalf("expected the caller's deadline to be kept but exec took: %v", elapsed)
+	}
+}
+
+func TestCallerCancelNotFailure(t *testing.T) {
+	const (
+		driver = "wrapper-caller-cancel"
+		insert = "insert into users values(1)"
+	)
+	mock, native := newMock()
+	drv, err := NewDriverWithOptions(driver, native, WithFailureThreshold(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	breaker := drv.(*Breaker)
+	db, err := sql.Open(driver, "caller-cancel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := db.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	mock.Slow(time.Second)
+
+	for i := 0; i < 3; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		if _, err := db.ExecContext(ctx, insert); err != context.DeadlineExceeded {
+			t.Fatalf("expected %v but got: %v", context.DeadlineExceeded, err)
+		}
+		cancel()
+		ctx, cancel = context.WithCancel(context.Background())
+		time.AfterFunc(10*time.Millisecond, cancel)
+		if _, err := db.ExecContext(ctx, insert); err != context.Canceled {
+			t.Fatalf("expected %v but got: %v", context.Canceled, err)
+		}
+	}
+	if state := breaker.State(); state != Closed {
+		t.Fatalf("expected callers giving up not to trip the breaker but got: %v", state)
+	}
+	if stats := breaker.Stats(); stats.Trips != 0 || stats.LastError != nil {
+		t.Fatalf("expected no trips but got: %+v", stats)
+	}
+}
@@ -0,0 +1,47 @@
+package dbreaker
+
+import (
+	"database/sql/driver"
+	"sync/atomic"
+)
+
+// tx is a transaction of the inner driver, counted as in flight until it ends
+type tx struct {
+	t     driver.Tx
+	w     *Breaker
+	c     *Conn       // connection the transaction runs on, if any
+	ended atomic.Bool // set once the transaction is no longer counted
+}
+
+// newTx wraps t, counting it as in flight
+func (w *Breaker) newTx(t driver.Tx) *tx {
+	w.enter()
+	return &tx{t: t, w: w}
+}
+
+// Commit commits the transaction
+func (t *tx) Commit() error {
+	defer t.end()
+	return t.t.Commit()
+}
+
+// Rollback aborts the transaction
+func (t *tx) Rollback() error {
+	defer t.end()
+	return t.t.Rollback()
+}
+
+// end stops counting the transaction as in flight, the first time it is called.
+//
+// The sql package only ends a transaction once, but the inner driver's
+// Commit or Rollback errors are returned as is and callers using the
+// driver directly may retry, which must not skew the count.
+func (t *tx) end() {
+	if t.ended.CompareAndSwap(false, true) {
+		if t.c != nil {
+			t.c.ro = false
+			t.c.tx = false
+		}
+		t.w.leave()
+	}
+}
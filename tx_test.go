@@ -0,0 +1,94 @@
+package dbreaker
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+)
+
+// inFlight returns the number of operations and transactions in flight
+func TestTxInFlight(t *testing.T) {
+	const wrapper = "wrapper-tx"
+	ctx := context.Background()
+	_, native := newMock()
+	drv, err := NewDriver(wrapper, native)
+	if err != nil {
+		t.Fatal(err)
+	}
+	breaker := drv.(*Breaker)
+
+	db, err := sql.Open(wrapper, "tx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	for _, end := range []string{"commit", "rollback"} {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := breaker.InFlight(); n != 1 {
+			t.Fatalf("expected 1 in flight before %s but got: %d", end, n)
+		}
+		if end == "commit" {
+			err = tx.Commit()
+		} else {
+			err = tx.Rollback()
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := breaker.InFlight(); n != 0 {
+			t.Fatalf("expected none in flight after %s but got: %d", end, n)
+		}
+	}
+}
+
+func TestTxEndOnce(t *testing.T) {
+	_, native := newMock()
+	breaker := newBreaker(native)
+	tx := breaker.newTx(mockTx{})
+	tx.Commit()
+	tx.Rollback()
+	if n := breaker.InFlight(); n != 0 {
+		t.Fatalf("expected none in flight but got: %d", n)
+	}
+}
+
+func TestTxReadOnly(t *testing.T) {
+	const wrapper = "wrapper-tx-read-only"
+	ctx := context.Background()
+	mock, native := newMock()
+	if _, err := NewDriver(wrapper, native); err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open(wrapper, "tx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.ExecContext(ctx, "insert into users values(1)"); err != ErrReadOnly {
+		t.Fatalf("expected %v but got: %v", ErrReadOnly, err)
+	}
+	if _, err := tx.QueryContext(ctx, "select * from users"); err != nil {
+		t.Fatalf("expected reads in the transaction but got: %v", err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the connection is writable again once the transaction ends
+	if _, err := db.ExecContext(ctx, "insert into users values(1)"); err != nil {
+		t.Fatalf("expected writes after the transaction but got: %v", err)
+	}
+	if n := len(mock.Execs()); n != 1 {
+		t.Fatalf("expected 1 exec but got: %d", n)
+	}
+}